@@ -2,320 +2,979 @@ package main
 
 import (
 	"bufio"
+	"compress/gzip"
+	"encoding/csv"
+	"encoding/json"
+	"errors"
+	"flag"
 	"fmt"
+	"io"
 	"math"
-	"math/rand"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strconv"
 	"strings"
+	"text/tabwriter"
 	"time"
+
+	"github.com/rohit907/vorto/vrp"
 )
 
-// Constants for the problem and algorithm parameters
+// Exit codes, so that scripts can tell why a run failed
 const (
-	maxShiftTime     = 720.0 // 12 hours in minutes
-	costPerDriver    = 500.0
-	tabuListSize     = 10
-	maxIterations    = 100
-	initialTabuValue = 1000.0
-	neighborhoodSize = 10
+	exitError      = 1 // Usage errors, unreadable files and other failures
+	exitInfeasible = 2 // The instance has no solution under its constraints
+	exitParse      = 3 // An input file is malformed
+	exitPartial    = 4 // A solution was found, but with -allow-drops it leaves loads undelivered
 )
 
-// Load represents a delivery task with pickup and dropoff locations
-type Load struct {
-	id      int
-	pickup  [2]float64
-	dropoff [2]float64
-}
+func main() {
+	// The flag package exits with 2 on a bad flag, which is exitInfeasible
+	// here, so parse errors are mapped to exitError instead
+	flag.CommandLine.Init(os.Args[0], flag.ContinueOnError)
+	defaults := vrp.DefaultOptions()
+	seed := flag.Int64("seed", 0, "seed for the random number generator (default: time-based)")
+	inputFormat := flag.String("input-format", "text", "format of the problem file: text or json")
+	format := flag.String("format", "text", "output format: "+strings.Join(outputFormats, ", "))
+	output := flag.String("output", "", "write the solution to this file instead of stdout")
+	precision := flag.Int("precision", 2, "decimal places of costs and times in the output and summary")
+	metric := flag.String("metric", "euclidean", "distance metric: euclidean, manhattan, chebyshev, haversine or weighted")
+	wx := flag.Float64("wx", 1, "x axis weight for -metric weighted")
+	wy := flag.Float64("wy", 1, "y axis weight for -metric weighted")
+	normalize := flag.Bool("normalize", false, "compute distances on coordinates centered on the depot and scaled to [-1,1], for huge coordinates")
+	matrixFile := flag.String("matrix", "", "CSV file with a precomputed (loads+1)x(loads+1) distance matrix, overriding -metric")
+	depotFlag := flag.String("depot", "0,0", "depot coordinate as x,y")
+	depotsFlag := flag.String("depots", "", "several depots as x,y;x,y; each route uses the nearest one at either end")
+	capacity := flag.Float64("capacity", 0, "maximum total demand per vehicle (0 for unlimited)")
+	groupsFile := flag.String("groups", "", "file of load ID groups, one per line, that must share a route")
+	conflictsFile := flag.String("conflicts", "", "file of load ID pairs, one per line, that must never share a route")
+	maxLoads := flag.Int("max-loads", 0, "maximum number of loads per route (0 for unlimited)")
+	shiftMinutes := flag.Float64("shift-minutes", vrp.MaxShiftTime, "longest a driver may work, in minutes")
+	allowDrops := flag.Bool("allow-drops", false, "leave loads undelivered, at a penalty, when they cannot all be served")
+	dropPenaltyFlag := flag.Float64("drop-penalty", defaults.DropPenalty, "cost of dropping a load with -allow-drops, multiplied by 1+priority")
+	maxDrivers := flag.Int("max-drivers", 0, "maximum number of drivers (routes) in the solution (0 for unlimited)")
+	openRoutes := flag.Bool("open-routes", false, "let drivers end at their last dropoff instead of returning to the depot")
+	allowReload := flag.Bool("allow-reload", false, "let vehicles return to the depot mid-route to unload when the next load exceeds -capacity")
+	waitingCost := flag.Bool("waiting-cost", false, "include time spent waiting for load ready times in the cost")
+	iterations := flag.Int("iterations", 0, "maximum number of search iterations (default 100, unlimited with -time-limit)")
+	restarts := flag.Int("restarts", 1, "number of independent search runs; the best result is kept")
+	eliteRestartProb := flag.Float64("elite-restart-prob", 0, "probability that a restart starts from a perturbed copy of an earlier elite solution")
+	timeLimit := flag.Duration("time-limit", 0, "wall-clock time budget for the search, e.g. 30s")
+	noImprove := flag.Int("no-improve", 0, "stop after this many iterations without improvement (0 to disable)")
+	algo := flag.String("algo", "tabu", "search algorithm: tabu or annealing")
+	gls := flag.Bool("gls", false, "guided local search: penalize the longest edges of local optima in the tabu search")
+	initMethod := flag.String("init", "random", "initial solution construction: random, greedy, insertion, sequential or savings")
+	temperature := flag.Float64("temperature", defaults.StartTemperature, "starting temperature for simulated annealing")
+	cooling := flag.Float64("cooling", defaults.CoolingRate, "geometric cooling rate for simulated annealing")
+	tabuSize := flag.Int("tabu-size", defaults.TabuListSize, "number of iterations a visited solution stays tabu")
+	neighborhood := flag.Int("neighborhood", defaults.NeighborhoodSize, "number of neighbors evaluated per iteration")
+	neighborhoodPct := flag.Float64("neighborhood-pct", 0, "neighbors evaluated per iteration as a percentage of the loads, clamped to 5-200; -neighborhood overrides it")
+	objective := flag.String("objective", "cost", "what the search minimizes: cost, balance or slack")
+	balanceWeight := flag.Float64("balance-weight", 0, "weight of the spread between the longest and shortest route in the objective")
+	driverCost := flag.Float64("driver-cost", defaults.CostPerDriver, "fixed cost per driver (route); 0 minimizes distance alone")
+	distanceWeight := flag.Float64("distance-weight", defaults.DistanceWeight, "weight of route time in the objective")
+	driverWeight := flag.Float64("driver-weight", defaults.DriverWeight, "weight of the driver cost in the objective")
+	intra := flag.String("intra", "2opt", "intra-route optimization: 2opt, or 3opt (alias lk)")
+	polish := flag.Bool("polish", defaults.Polish, "merge routes of the final solution, then reorder each with the -intra optimization")
+	workers := flag.Int("workers", runtime.NumCPU(), "number of goroutines evaluating neighbors; 1 evaluates sequentially")
+	verbose := flag.Bool("v", false, "log search progress to stderr")
+	repl := flag.Bool("repl", false, "build an initial solution and apply moves to it interactively, reading commands from stdin")
+	quiet := flag.Bool("quiet", false, "print only the solution, and errors; no seed, summary or log lines")
+	traceFile := flag.String("trace", "", "write an iteration,current_cost,best_cost CSV row per search iteration to this file")
+	stats := flag.Bool("stats", false, "validate the problem and print instance statistics without solving")
+	dir := flag.String("dir", "", "solve every *.txt problem in a directory and print a summary table")
+	if err := flag.CommandLine.Parse(os.Args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			os.Exit(0)
+		}
+		os.Exit(exitError)
+	}
 
-// Solution represents a set of routes and their associated cost
-type Solution struct {
-	routes [][]int
-	cost   float64
-}
+	// Check if a data file path is provided
+	if flag.NArg() < 1 && *dir == "" {
+		fmt.Fprintln(os.Stderr, "Please provide a data file path.")
+		os.Exit(exitError)
+	}
+	if *inputFormat != "text" && *inputFormat != "json" {
+		fmt.Fprintf(os.Stderr, "Unknown input format %q\n", *inputFormat)
+		os.Exit(exitError)
+	}
+	if !isOutputFormat(*format) {
+		fmt.Fprintf(os.Stderr, "Unknown output format %q\n", *format)
+		os.Exit(exitError)
+	}
+	// The weighted metric is parameterized by -wx and -wy, so it is not registered by name
+	distance := vrp.WeightedDistance(*wx, *wy)
+	var err error
+	if *metric != "weighted" {
+		distance, err = vrp.MetricByName(*metric)
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+		os.Exit(exitError)
+	}
+	if *precision < 0 {
+		fmt.Fprintln(os.Stderr, "Error: -precision must not be negative")
+		os.Exit(exitError)
+	}
+	if *shiftMinutes <= 0 {
+		fmt.Fprintln(os.Stderr, "Error: -shift-minutes must be positive")
+		os.Exit(exitError)
+	}
+	if *quiet && *verbose {
+		fmt.Fprintln(os.Stderr, "Error: -quiet cannot be used with -v")
+		os.Exit(exitError)
+	}
+	if *normalize && *metric == "haversine" {
+		fmt.Fprintln(os.Stderr, "Error: -normalize cannot be used with -metric haversine")
+		os.Exit(exitError)
+	}
+	depot, err := vrp.ParseCoordinates(*depotFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing -depot: %v\n", err)
+		os.Exit(exitError)
+	}
+	depots, err := parseDepots(*depotsFlag)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error parsing -depots: %v\n", err)
+		os.Exit(exitError)
+	}
 
-// Global variables to store problem data and precomputed distances
-var (
-	loads            []Load
-	distanceMatrix   [][]float64
-	deliveryDistance []float64
-)
+	groups, err := readGroupsFile(*groupsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading -groups: %v\n", err)
+		os.Exit(exitCode(err))
+	}
 
-func main() {
-	// Check if a data file path is provided
-	if len(os.Args) < 2 {
-		fmt.Println("Please provide a data file path.")
+	conflicts, err := readConflictsFile(*conflictsFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading -conflicts: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	matrix, err := readMatrixFile(*matrixFile)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading -matrix: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if *neighborhoodPct < 0 || *neighborhoodPct > 100 {
+		fmt.Fprintln(os.Stderr, "Error: -neighborhood-pct must be between 0 and 100")
+		os.Exit(exitError)
+	}
+	// An explicit -neighborhood takes precedence over -neighborhood-pct
+	if *neighborhoodPct > 0 && !isFlagSet("neighborhood") {
+		*neighborhood = 0
+	}
+
+	// Fall back to a time-based seed unless one was given explicitly
+	if !isFlagSet("seed") {
+		*seed = time.Now().UnixNano()
+	}
+
+	opts := vrp.Options{
+		Seed:           *seed,
+		Distance:       distance,
+		Normalize:      *normalize,
+		Matrix:         matrix,
+		Depot:          depot,
+		Depots:         depots,
+		Groups:         groups,
+		Conflicts:      conflicts,
+		Capacity:       *capacity,
+		MaxLoads:       *maxLoads,
+		MaxDrivers:     *maxDrivers,
+		ShiftTime:      *shiftMinutes,
+		AllowReload:    *allowReload,
+		OpenRoutes:     *openRoutes,
+		AllowDrops:     *allowDrops,
+		DropPenalty:    *dropPenaltyFlag,
+		CostPerDriver:  *driverCost,
+		DistanceWeight: *distanceWeight,
+		DriverWeight:   *driverWeight,
+		WaitingCost:    *waitingCost,
+		Objective:      *objective,
+		BalanceWeight:  *balanceWeight,
+		MaxIterations:  *iterations,
+		TimeLimit:      *timeLimit,
+		NoImprove:      *noImprove,
+		Restarts:       *restarts,
+
+		EliteRestartProb: *eliteRestartProb,
+
+		TabuListSize:     *tabuSize,
+		NeighborhoodSize: *neighborhood,
+
+		NeighborhoodFraction: *neighborhoodPct / 100,
+
+		Algorithm:         *algo,
+		GuidedLocalSearch: *gls,
+		Init:              *initMethod,
+		Intra:             *intra,
+		StartTemperature:  *temperature,
+		CoolingRate:       *cooling,
+		Polish:            *polish,
+		Workers:           *workers,
+	}
+	if *verbose {
+		opts.Log = os.Stderr
+	}
+
+	// verify <problem> <solution> checks an existing solution instead of solving
+	if flag.Arg(0) == "verify" {
+		if flag.NArg() != 3 {
+			fmt.Fprintln(os.Stderr, "Usage: verify <problem> <solution>")
+			os.Exit(exitError)
+		}
+		if err := runVerify(flag.Arg(1), flag.Arg(2), *inputFormat, opts, *precision); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	// diff <problem> <solutionA> <solutionB> compares two existing solutions
+	if flag.Arg(0) == "diff" {
+		if flag.NArg() != 4 {
+			fmt.Fprintln(os.Stderr, "Usage: diff <problem> <solutionA> <solutionB>")
+			os.Exit(exitError)
+		}
+		if err := runDiff(flag.Arg(1), flag.Arg(2), flag.Arg(3), *inputFormat, opts, *precision); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	if *stats {
+		if err := runStats(flag.Args(), *inputFormat, opts); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
 		return
 	}
 
-	dataFile := os.Args[1]
-	// Read loads from the provided file
-	if err := readLoads(dataFile); err != nil {
-		fmt.Printf("Error reading file: %v\n", err)
+	if !*quiet {
+		fmt.Fprintf(os.Stderr, "seed=%d\n", *seed)
+	}
+
+	if *dir != "" {
+		if err := runDirectory(*dir, *inputFormat, opts, *precision); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
 		return
 	}
 
-	// Initialize distance matrices
-	initializeMatrices()
-	// Run the tabu search algorithm
-	bestSolution := tabuSearch()
+	if *repl {
+		for _, arg := range flag.Args() {
+			if arg == "-" {
+				fmt.Fprintln(os.Stderr, "Error: -repl reads commands from stdin, so the problem cannot be read from it")
+				os.Exit(exitError)
+			}
+		}
+	}
+	// Read loads from the provided files
+	loads, err := readLoadsFiles(flag.Args(), *inputFormat)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error reading file: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+
+	if *verbose {
+		fmt.Fprintf(os.Stderr, "instance=%s\n", vrp.InstanceHash(&vrp.Problem{Loads: loads}))
+	}
+
+	if *repl {
+		if err := runREPL(loads, opts, *precision, os.Stdin, os.Stdout); err != nil {
+			fmt.Fprintf(os.Stderr, "Error: %v\n", err)
+			os.Exit(exitCode(err))
+		}
+		return
+	}
+
+	var trace *bufio.Writer
+	if *traceFile != "" {
+		file, err := os.Create(*traceFile)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error creating -trace: %v\n", err)
+			os.Exit(exitError)
+		}
+		defer file.Close()
+		trace = bufio.NewWriter(file)
+		opts.Trace = trace
+	}
+
+	// Run the solver
+	bestSolution, err := vrp.Solve(&vrp.Problem{Loads: loads}, opts)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Error solving problem: %v\n", err)
+		os.Exit(exitCode(err))
+	}
+	if trace != nil {
+		if err := trace.Flush(); err != nil {
+			fmt.Fprintf(os.Stderr, "Error writing -trace: %v\n", err)
+			os.Exit(exitError)
+		}
+	}
 	// Print the best solution found
-	printSolution(bestSolution)
+	geo := geoSource{loads: loads, depots: depots, distance: distance, latLon: *metric == "haversine", open: *openRoutes}
+	if len(geo.depots) == 0 {
+		geo.depots = [][2]float64{depot}
+	}
+	if err := writeSolution(*output, bestSolution, *format, *shiftMinutes, *precision, geo); err != nil {
+		fmt.Fprintf(os.Stderr, "Error writing solution: %v\n", err)
+		os.Exit(exitError)
+	}
+	if !*quiet {
+		printSummary(bestSolution, *precision, loads)
+	}
+	if len(bestSolution.Dropped) > 0 {
+		os.Exit(exitPartial)
+	}
+}
+
+// exitCode picks the exit code for an error: exitParse for malformed input,
+// exitInfeasible for an instance that cannot be solved, exitError otherwise
+func exitCode(err error) int {
+	var parseErr *vrp.ParseError
+	var infeasibleErr *vrp.InfeasibleError
+	switch {
+	case errors.As(err, &parseErr):
+		return exitParse
+	case errors.As(err, &infeasibleErr):
+		return exitInfeasible
+	}
+	return exitError
+}
+
+// isFlagSet reports whether the named flag was provided on the command line
+func isFlagSet(name string) bool {
+	set := false
+	flag.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// parseDepots parses a semicolon-separated list of x,y depot coordinates,
+// returning nil for an empty list
+func parseDepots(list string) ([][2]float64, error) {
+	if strings.TrimSpace(list) == "" {
+		return nil, nil
+	}
+	var depots [][2]float64
+	for _, field := range strings.Split(list, ";") {
+		depot, err := vrp.ParseCoordinates(field)
+		if err != nil {
+			return nil, err
+		}
+		depots = append(depots, depot)
+	}
+	return depots, nil
 }
 
-// readLoads reads load data from the specified file
-func readLoads(filename string) error {
-	// Open and read the file
+// readGroupsFile reads pinned load groups from a file, returning nil when no
+// file is given
+func readGroupsFile(filename string) ([][]int, error) {
+	if filename == "" {
+		return nil, nil
+	}
 	file, err := os.Open(filename)
 	if err != nil {
-		return err
+		return nil, err
 	}
 	defer file.Close()
+	return vrp.ReadGroups(file)
+}
 
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "loadNumber") {
-			continue // Skip header line
-		}
-		// Parse load data and add to loads slice
-		parts := strings.Fields(line)
-		id, _ := strconv.Atoi(parts[0])
-		pickup := parseCoordinates(parts[1])
-		dropoff := parseCoordinates(parts[2])
-		loads = append(loads, Load{id, pickup, dropoff})
+// readConflictsFile reads forbidden load pairs from a file, returning nil when
+// no file is given
+func readConflictsFile(filename string) ([][2]int, error) {
+	if filename == "" {
+		return nil, nil
 	}
-	return scanner.Err()
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return vrp.ReadConflicts(file)
 }
 
-// parseCoordinates converts a string coordinate to a float64 pair
-func parseCoordinates(coord string) [2]float64 {
-	coord = strings.Trim(coord, "()")
-	parts := strings.Split(coord, ",")
-	x, _ := strconv.ParseFloat(parts[0], 64)
-	y, _ := strconv.ParseFloat(parts[1], 64)
-	return [2]float64{x, y}
+// readMatrixFile reads a precomputed distance matrix from a file, returning
+// nil when no file is given
+func readMatrixFile(filename string) ([][]float64, error) {
+	if filename == "" {
+		return nil, nil
+	}
+	file, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return vrp.ReadMatrix(file)
 }
 
-// initializeMatrices precomputes distance matrices for efficiency
-func initializeMatrices() {
-	totalLoads := len(loads)
-	deliveryDistance = make([]float64, totalLoads)
-	distanceMatrix = make([][]float64, totalLoads+1)
+// readLoadsFile reads load data from the specified file, or from stdin when the
+// path is "-", in the given input format. Gzip-compressed input is
+// decompressed automatically.
+func readLoadsFile(filename, inputFormat string) ([]vrp.Load, error) {
+	var input io.Reader = os.Stdin
+	if filename != "-" {
+		// Open and read the file
+		file, err := os.Open(filename)
+		if err != nil {
+			return nil, err
+		}
+		defer file.Close()
+		input = file
+	}
+
+	// Transparently decompress gzip input, detected by its magic header so
+	// that compressed stdin works as well as .gz files
+	buffered := bufio.NewReader(input)
+	input = buffered
+	if magic, err := buffered.Peek(2); err == nil && magic[0] == 0x1f && magic[1] == 0x8b {
+		gz, err := gzip.NewReader(buffered)
+		if err != nil {
+			return nil, err
+		}
+		defer gz.Close()
+		input = gz
+	}
 
-	for i := range distanceMatrix {
-		distanceMatrix[i] = make([]float64, totalLoads+1)
+	readLoads := vrp.ReadLoads
+	if inputFormat == "json" {
+		readLoads = vrp.ReadLoadsJSON
+	}
+	loads, err := readLoads(input)
+	if err != nil {
+		return nil, err
 	}
+	if len(loads) == 0 {
+		return nil, errors.New("no loads found in input")
+	}
+	return loads, nil
+}
 
-	// Calculate distances between loads and depot
-	for i, load := range loads {
-		deliveryDistance[i] = euclideanDistance(load.pickup, load.dropoff)
-		distanceMatrix[0][i+1] = euclideanDistance([2]float64{0, 0}, load.pickup)
-		distanceMatrix[i+1][0] = euclideanDistance(load.dropoff, [2]float64{0, 0})
-		for j, otherLoad := range loads {
-			if i != j {
-				distanceMatrix[i+1][j+1] = euclideanDistance(load.dropoff, otherLoad.pickup)
+// outputFormats lists the values accepted by -format
+var outputFormats = []string{"text", "json", "csv", "detailed", "geojson"}
+
+// readLoadsFiles reads the loads of every file and appends them into one
+// problem, in the order given. Load IDs are kept for output, so they must be
+// unique across the files.
+func readLoadsFiles(filenames []string, inputFormat string) ([]vrp.Load, error) {
+	if len(filenames) == 1 {
+		return readLoadsFile(filenames[0], inputFormat)
+	}
+	var loads []vrp.Load
+	fileOf := make(map[int]string)
+	for _, filename := range filenames {
+		fileLoads, err := readLoadsFile(filename, inputFormat)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", filename, err)
+		}
+		for _, load := range fileLoads {
+			if first, ok := fileOf[load.ID]; ok {
+				return nil, &vrp.ParseError{Reason: fmt.Sprintf("load id %d is in both %s and %s", load.ID, first, filename)}
 			}
+			fileOf[load.ID] = filename
 		}
+		loads = append(loads, fileLoads...)
 	}
+	return loads, nil
 }
 
-// euclideanDistance calculates the Euclidean distance between two points
-func euclideanDistance(a, b [2]float64) float64 {
-	return math.Sqrt(math.Pow(a[0]-b[0], 2) + math.Pow(a[1]-b[1], 2))
+// isOutputFormat reports whether format is a supported output format
+func isOutputFormat(format string) bool {
+	for _, f := range outputFormats {
+		if f == format {
+			return true
+		}
+	}
+	return false
 }
 
-// tabuSearch implements the Tabu Search algorithm
-func tabuSearch() Solution {
-	rand.Seed(time.Now().UnixNano())
-
-	// Initialize a random initial solution
-	currentSolution := generateInitialSolution()
-	bestSolution := currentSolution
+// writeSolution prints the solution to the named file, created or truncated,
+// or to stdout when no file is given
+func writeSolution(filename string, solution vrp.Solution, format string, shift float64, precision int, geo geoSource) error {
+	if filename == "" {
+		return printSolution(os.Stdout, solution, format, shift, precision, geo)
+	}
+	file, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := printSolution(file, solution, format, shift, precision, geo); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
 
-	tabuList := make(map[string]float64)
-	tabuCounter := make(map[string]int)
+// printSolution outputs the solution in the requested format, with the load
+// IDs from the problem file. geo.loads translates route indices to those IDs;
+// the rest of geo is only used by the geojson format, and the shift limit in
+// minutes only by the detailed format.
+func printSolution(w io.Writer, solution vrp.Solution, format string, shift float64, precision int, geo geoSource) error {
+	if format == "geojson" {
+		return printSolutionGeoJSON(w, solution, geo)
+	}
+	solution.Routes = loadIDs(solution.Routes, geo.loads)
+	solution.Dropped = loadIDs([][]int{solution.Dropped}, geo.loads)[0]
+	switch format {
+	case "json":
+		return printSolutionJSON(w, solution, precision, vrp.InstanceHash(&vrp.Problem{Loads: geo.loads}))
+	case "csv":
+		return printSolutionCSV(w, solution)
+	case "detailed":
+		return printSolutionDetailed(w, solution, shift, precision)
+	}
+	for _, route := range solution.Routes {
+		if _, err := fmt.Fprintf(w, "%s\n", formatRoute(route)); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-	// Main loop of the Tabu Search algorithm
-	for iteration := 0; iteration < maxIterations; iteration++ {
-		neighbors := generateNeighborhood(currentSolution)
-		bestNeighbor := Solution{cost: math.Inf(1)}
+// loadIDs translates routes of 1-based load indices into the loads' own IDs
+func loadIDs(routes [][]int, loads []vrp.Load) [][]int {
+	if vrp.HasSequentialIDs(loads) {
+		return routes
+	}
+	translated := make([][]int, len(routes))
+	for i, route := range routes {
+		translated[i] = make([]int, len(route))
+		for j, node := range route {
+			translated[i][j] = loads[node-1].ID
+		}
+	}
+	return translated
+}
 
-		// Find the best non-tabu neighbor
-		for _, neighbor := range neighbors {
-			if tabuValue, ok := tabuList[neighborKey(neighbor)]; ok && tabuValue > 0 {
-				continue
-			}
-			if neighbor.cost < bestNeighbor.cost {
-				bestNeighbor = neighbor
+// loadIndices translates routes of load IDs into 1-based load indices,
+// failing on an ID that is not in the problem
+func loadIndices(routes [][]int, loads []vrp.Load) ([][]int, error) {
+	index := make(map[int]int, len(loads))
+	for i, load := range loads {
+		index[load.ID] = i + 1
+	}
+	translated := make([][]int, len(routes))
+	for i, route := range routes {
+		translated[i] = make([]int, len(route))
+		for j, id := range route {
+			node, ok := index[id]
+			if !ok {
+				return nil, fmt.Errorf("route %d references unknown load %d", i, id)
 			}
+			translated[i][j] = node
 		}
+	}
+	return translated, nil
+}
 
-		// Update best solution if necessary
-		if bestNeighbor.cost < bestSolution.cost {
-			bestSolution = bestNeighbor
-		}
+// formatRoute renders a route as [1,2,3]
+func formatRoute(route []int) string {
+	return fmt.Sprintf("[%s]", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(route)), ","), "[]"))
+}
 
-		// Update tabu list
-		updateTabuList(tabuList, tabuCounter, bestNeighbor)
+// printSolutionDetailed outputs each route annotated with its load count,
+// total time and slack against the shift limit
+func printSolutionDetailed(w io.Writer, solution vrp.Solution, shift float64, precision int) error {
+	for i, route := range solution.Routes {
+		routeTime := solution.RouteTimes[i]
+		if _, err := fmt.Fprintf(w, "route %d: %s loads=%d time=%.*f slack=%.*f\n", i, formatRoute(route), len(route), precision, routeTime, precision, shift-routeTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
 
-		currentSolution = bestNeighbor
+// printSolutionJSON outputs the solution as a single JSON object, keyed by
+// the hash of the instance it solves
+func printSolutionJSON(w io.Writer, solution vrp.Solution, precision int, instance string) error {
+	routes := solution.Routes
+	if routes == nil {
+		routes = [][]int{} // Encode as [] rather than null
 	}
+	return json.NewEncoder(w).Encode(struct {
+		Routes   [][]int `json:"routes"`
+		Cost     float64 `json:"cost"`
+		Drivers  int     `json:"drivers"`
+		Instance string  `json:"instance"`
+		Dropped  []int   `json:"dropped,omitempty"`
+	}{routes, round(solution.Cost, precision), len(solution.Routes), instance, solution.Dropped})
+}
 
-	return bestSolution
+// round rounds x to the given number of decimal places
+func round(x float64, precision int) float64 {
+	scale := math.Pow(10, float64(precision))
+	return math.Round(x*scale) / scale
 }
 
-// generateInitialSolution creates a random initial solution
-func generateInitialSolution() Solution {
-	var solution Solution
-	remainingLoads := make([]int, len(loads))
-	for i := range remainingLoads {
-		remainingLoads[i] = i + 1
-	}
+// geoSource holds the coordinates needed to draw a solution on a map
+type geoSource struct {
+	loads    []vrp.Load
+	depots   [][2]float64
+	distance vrp.DistanceFunc
+	latLon   bool // Coordinates are (latitude, longitude) and must be swapped
+	open     bool // Routes end at their last dropoff
+}
 
-	// Create routes until all loads are assigned
-	for len(remainingLoads) > 0 {
-		var route []int
-		currentNode := 0
-		routeTime := 0.0
+// position converts a coordinate to a GeoJSON [x, y] position
+func (g geoSource) position(point [2]float64) [2]float64 {
+	if g.latLon {
+		return [2]float64{point[1], point[0]}
+	}
+	return point
+}
 
-		// Build a single route
-		for len(remainingLoads) > 0 {
-			nextNode := selectNextNode(currentNode, remainingLoads, routeTime)
-			if nextNode == 0 {
-				break
-			}
-			route = append(route, nextNode)
-			routeTime += distanceMatrix[currentNode][nextNode] + deliveryDistance[nextNode-1]
-			currentNode = nextNode
-			// Remove the selected load from remainingLoads
-			for i, load := range remainingLoads {
-				if load == nextNode {
-					remainingLoads = append(remainingLoads[:i], remainingLoads[i+1:]...)
-					break
-				}
-			}
+// nearestDepot returns the depot closest to point, matching how the solver
+// picks the depot at either end of a route
+func (g geoSource) nearestDepot(point [2]float64, toDepot bool) [2]float64 {
+	nearest := g.depots[0]
+	nearestDistance := math.Inf(1)
+	for _, depot := range g.depots {
+		distance := g.distance(depot, point)
+		if toDepot {
+			distance = g.distance(point, depot)
 		}
-
-		if len(route) > 0 {
-			solution.routes = append(solution.routes, route)
+		if distance < nearestDistance {
+			nearest, nearestDistance = depot, distance
 		}
 	}
+	return nearest
+}
 
-	solution.cost = calculateCost(solution)
-	return solution
+// geoFeature is a GeoJSON feature with a Point or LineString geometry
+type geoFeature struct {
+	Type     string `json:"type"`
+	Geometry struct {
+		Type        string      `json:"type"`
+		Coordinates interface{} `json:"coordinates"`
+	} `json:"geometry"`
+	Properties map[string]interface{} `json:"properties"`
 }
 
-// generateNeighborhood creates a set of neighbor solutions
-func generateNeighborhood(solution Solution) []Solution {
-	var neighbors []Solution
+// newGeoFeature builds a feature of the given geometry type
+func newGeoFeature(geometry string, coordinates interface{}, properties map[string]interface{}) geoFeature {
+	feature := geoFeature{Type: "Feature", Properties: properties}
+	feature.Geometry.Type = geometry
+	feature.Geometry.Coordinates = coordinates
+	return feature
+}
 
-	for i := 0; i < neighborhoodSize; i++ {
-		neighbor := swapRandomRoutes(solution)
-		neighbor.cost = calculateCost(neighbor)
-		neighbors = append(neighbors, neighbor)
+// printSolutionGeoJSON outputs a FeatureCollection with a LineString per route,
+// running depot, pickups and dropoffs, depot, and a pickup and a dropoff Point
+// per load. Every feature carries the 0-based index of its route.
+func printSolutionGeoJSON(w io.Writer, solution vrp.Solution, geo geoSource) error {
+	features := []geoFeature{}
+	for i, route := range solution.Routes {
+		first, last := geo.loads[route[0]-1], geo.loads[route[len(route)-1]-1]
+		line := [][2]float64{geo.position(geo.nearestDepot(first.Pickup, false))}
+		for _, node := range route {
+			load := geo.loads[node-1]
+			line = append(line, geo.position(load.Pickup), geo.position(load.Dropoff))
+			features = append(features,
+				newGeoFeature("Point", geo.position(load.Pickup), map[string]interface{}{"route": i, "load": load.ID, "stop": "pickup"}),
+				newGeoFeature("Point", geo.position(load.Dropoff), map[string]interface{}{"route": i, "load": load.ID, "stop": "dropoff"}))
+		}
+		if !geo.open {
+			line = append(line, geo.position(geo.nearestDepot(last.Dropoff, true)))
+		}
+		features = append(features, newGeoFeature("LineString", line, map[string]interface{}{"route": i, "loads": len(route)}))
 	}
+	return json.NewEncoder(w).Encode(struct {
+		Type     string       `json:"type"`
+		Features []geoFeature `json:"features"`
+	}{"FeatureCollection", features})
+}
 
-	return neighbors
+// printSolutionCSV outputs one row per load with its 0-based route index and
+// 1-based position within the route
+func printSolutionCSV(w io.Writer, solution vrp.Solution) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"load_id", "route_index", "sequence_in_route"})
+	for i, route := range solution.Routes {
+		for j, node := range route {
+			cw.Write([]string{strconv.Itoa(node), strconv.Itoa(i), strconv.Itoa(j + 1)})
+		}
+	}
+	cw.Flush()
+	return cw.Error()
 }
 
-// swapRandomRoutes creates a new solution by swapping two random routes
-func swapRandomRoutes(solution Solution) Solution {
-	// Clone solution and swap routes
-	var newSolution Solution
-	newSolution.routes = make([][]int, len(solution.routes))
-	copy(newSolution.routes, solution.routes)
+// printSummary writes the driver count and cost breakdown of the solution to
+// stderr, followed by the IDs of any dropped loads
+func printSummary(solution vrp.Solution, precision int, loads []vrp.Load) {
+	drivers, distance := len(solution.Routes), solution.Distance
+	if len(solution.Dropped) == 0 {
+		fmt.Fprintf(os.Stderr, "drivers=%d total_cost=%.*f total_distance=%.*f\n", drivers, precision, solution.Cost, precision, distance)
+		return
+	}
+	fmt.Fprintf(os.Stderr, "drivers=%d total_cost=%.*f total_distance=%.*f dropped=%d drop_penalty=%.*f\n", drivers, precision, solution.Cost, precision, distance, len(solution.Dropped), precision, solution.DropPenalty)
+	fmt.Fprintf(os.Stderr, "dropped loads: %s\n", formatRoute(loadIDs([][]int{solution.Dropped}, loads)[0]))
+}
 
-	if len(newSolution.routes) < 2 {
-		return newSolution
+// runDirectory solves every problem in dir, *.txt files or *.json files for
+// JSON input, and prints a summary table
+func runDirectory(dir, inputFormat string, opts vrp.Options, precision int) error {
+	pattern := "*.txt"
+	if inputFormat == "json" {
+		pattern = "*.json"
+	}
+	files, err := filepath.Glob(filepath.Join(dir, pattern))
+	if err != nil {
+		return err
 	}
+	if len(files) == 0 {
+		return fmt.Errorf("no %s problems found in %s", pattern, dir)
+	}
+
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "instance\tloads\tdrivers\tcost\ttime\t")
+
+	totalCost := 0.0
+	solved := 0
+	for _, file := range files {
+		name := filepath.Base(file)
+		loads, err := readLoadsFile(file, inputFormat)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error reading %s: %v\n", name, err)
+			continue
+		}
 
-	i, j := rand.Intn(len(newSolution.routes)), rand.Intn(len(newSolution.routes))
-	for i == j {
-		j = rand.Intn(len(newSolution.routes))
+		start := time.Now()
+		solution, err := vrp.Solve(&vrp.Problem{Loads: loads}, opts)
+		elapsed := time.Since(start)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "Error solving %s: %v\n", name, err)
+			continue
+		}
+
+		fmt.Fprintf(w, "%s\t%d\t%d\t%.*f\t%s\t\n", name, len(loads), len(solution.Routes), precision, solution.Cost, elapsed.Round(time.Millisecond))
+		totalCost += solution.Cost
+		solved++
+	}
+	if err := w.Flush(); err != nil {
+		return err
 	}
 
-	newSolution.routes[i], newSolution.routes[j] = newSolution.routes[j], newSolution.routes[i]
+	if solved == 0 {
+		return fmt.Errorf("no problems in %s could be solved", dir)
+	}
+	fmt.Printf("mean cost: %.*f over %d instances\n", precision, totalCost/float64(solved), solved)
+	return nil
+}
 
-	return newSolution
+// runVerify checks a solution file against a problem file and prints its recomputed cost
+func runVerify(problemFile, solutionFile, inputFormat string, opts vrp.Options, precision int) error {
+	loads, err := readLoadsFile(problemFile, inputFormat)
+	if err != nil {
+		return fmt.Errorf("reading problem: %w", err)
+	}
+	solution, err := evaluateFile(solutionFile, loads, opts)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("valid: drivers=%d total_cost=%.*f\n", len(solution.Routes), precision, solution.Cost)
+	return nil
 }
 
-// selectNextNode chooses the next load to add to a route
-func selectNextNode(currentNode int, remainingLoads []int, routeTime float64) int {
-	var probabilities []float64
-	var sum float64
+// evaluateFile reads a route file of load IDs and evaluates it against the loads
+func evaluateFile(solutionFile string, loads []vrp.Load, opts vrp.Options) (vrp.Solution, error) {
+	file, err := os.Open(solutionFile)
+	if err != nil {
+		return vrp.Solution{}, fmt.Errorf("reading solution: %w", err)
+	}
+	defer file.Close()
+	routes, err := vrp.ReadRoutes(file)
+	if err != nil {
+		return vrp.Solution{}, fmt.Errorf("reading solution: %w", err)
+	}
+	if routes, err = loadIndices(routes, loads); err != nil {
+		return vrp.Solution{}, fmt.Errorf("invalid solution: %w", err)
+	}
 
-	// Calculate probabilities for each remaining load
-	for _, load := range remainingLoads {
-		if routeTime+distanceMatrix[currentNode][load]+deliveryDistance[load-1]+distanceMatrix[load][0] > maxShiftTime {
-			probabilities = append(probabilities, 0)
-		} else {
-			probability := 1.0 / distanceMatrix[currentNode][load]
-			probabilities = append(probabilities, probability)
-			sum += probability
-		}
+	solution, err := vrp.Evaluate(&vrp.Problem{Loads: loads}, routes, opts)
+	if err != nil {
+		return vrp.Solution{}, fmt.Errorf("invalid solution: %w", err)
 	}
+	return solution, nil
+}
 
-	if sum == 0 {
-		return 0
+// runDiff evaluates two solution files against a problem file and prints how
+// the second differs from the first: the cost and driver deltas and every load
+// that changed route
+func runDiff(problemFile, solutionA, solutionB, inputFormat string, opts vrp.Options, precision int) error {
+	loads, err := readLoadsFile(problemFile, inputFormat)
+	if err != nil {
+		return fmt.Errorf("reading problem: %w", err)
+	}
+	a, err := evaluateFile(solutionA, loads, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", solutionA, err)
+	}
+	b, err := evaluateFile(solutionB, loads, opts)
+	if err != nil {
+		return fmt.Errorf("%s: %w", solutionB, err)
 	}
 
-	// Select a load based on the calculated probabilities
-	randomValue := rand.Float64() * sum
-	for i, probability := range probabilities {
-		randomValue -= probability
-		if randomValue <= 0 {
-			return remainingLoads[i]
-		}
+	fmt.Printf("a: drivers=%d total_cost=%.*f\n", len(a.Routes), precision, a.Cost)
+	fmt.Printf("b: drivers=%d total_cost=%.*f\n", len(b.Routes), precision, b.Cost)
+	fmt.Printf("delta: drivers=%+d total_cost=%+.*f\n", len(b.Routes)-len(a.Routes), precision, b.Cost-a.Cost)
+
+	moves := vrp.MovedLoads(loadIDs(a.Routes, loads), loadIDs(b.Routes, loads))
+	fmt.Printf("moved loads: %d\n", len(moves))
+	for _, move := range moves {
+		fmt.Printf("load %d: %s -> %s\n", move.Load, routeName(move.From), routeName(move.To))
+	}
+	return nil
+}
+
+// routeName describes a route number reported by vrp.MovedLoads
+func routeName(route int) string {
+	if route < 0 {
+		return "dropped"
+	}
+	return fmt.Sprintf("route %d", route)
+}
+
+// runStats validates the problem in one or more files and prints its statistics without solving it
+func runStats(problemFiles []string, inputFormat string, opts vrp.Options) error {
+	loads, err := readLoadsFiles(problemFiles, inputFormat)
+	if err != nil {
+		return fmt.Errorf("reading problem: %w", err)
+	}
+	stats, err := vrp.Stats(&vrp.Problem{Loads: loads}, opts)
+	if err != nil {
+		return err
 	}
 
-	return 0
+	fmt.Printf("loads: %d\n", stats.Loads)
+	fmt.Printf("bounding box: (%.2f,%.2f) to (%.2f,%.2f)\n", stats.Min[0], stats.Min[1], stats.Max[0], stats.Max[1])
+	fmt.Printf("total delivery distance: %.2f\n", stats.TotalDelivery)
+	fmt.Printf("round trip time: min %.2f max %.2f mean %.2f\n", stats.MinRoundTrip, stats.MaxRoundTrip, stats.MeanRoundTrip)
+	fmt.Printf("minimum drivers: %d\n", stats.MinDrivers)
+	fmt.Printf("instance hash: %s\n", vrp.InstanceHash(&vrp.Problem{Loads: loads}))
+	return nil
 }
 
-// updateTabuList manages the tabu list, adding new entries and removing old ones
-func updateTabuList(tabuList map[string]float64, tabuCounter map[string]int, solution Solution) {
-	key := neighborKey(solution)
-	if len(tabuList) >= tabuListSize {
-		for k := range tabuList {
-			if tabuCounter[k] > 0 {
-				tabuCounter[k]--
+// replHelp lists the commands runREPL accepts
+const replHelp = `commands:
+  relocate <load> <route>  move a load to its best position in a route
+  swap <load> <load>       exchange two loads on different routes
+  2opt <route>             reorder a route with the -intra optimization
+  eliminate <route>        empty a route into the others
+  undo                     revert the last move
+  show                     print the current solution
+  help                     print this list
+  quit                     leave`
+
+// runREPL builds an initial solution and applies the moves typed on in to it,
+// printing the solution and its cost after each one. Loads are named by ID and
+// routes by their number in the printed solution.
+func runREPL(loads []vrp.Load, opts vrp.Options, precision int, in io.Reader, out io.Writer) error {
+	session, err := vrp.NewSession(&vrp.Problem{Loads: loads}, opts)
+	if err != nil {
+		return err
+	}
+	index := make(map[int]int, len(loads))
+	for i, load := range loads {
+		index[load.ID] = i + 1
+	}
+
+	fmt.Fprintln(out, replHelp)
+	printSession(out, session.Solution(), loads, precision)
+	fmt.Fprint(out, "> ")
+	scanner := bufio.NewScanner(in)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) > 0 {
+			if fields[0] == "quit" || fields[0] == "exit" {
+				return nil
 			}
-			if tabuCounter[k] == 0 {
-				delete(tabuList, k)
-				delete(tabuCounter, k)
+			if err := runCommand(session, fields, index); err != nil {
+				fmt.Fprintf(out, "error: %v\n", err)
+			} else if fields[0] == "help" {
+				fmt.Fprintln(out, replHelp)
+			} else {
+				printSession(out, session.Solution(), loads, precision)
 			}
 		}
+		fmt.Fprint(out, "> ")
 	}
-	tabuList[key] = initialTabuValue
-	tabuCounter[key] = tabuListSize
+	fmt.Fprintln(out)
+	return scanner.Err()
 }
 
-// neighborKey generates a unique key for a solution
-func neighborKey(solution Solution) string {
-	var sb strings.Builder
-	for _, route := range solution.routes {
-		sb.WriteString(fmt.Sprintf("%v-", route))
+// runCommand applies one REPL command to the session, translating load IDs
+// into the indices the session uses
+func runCommand(session *vrp.Session, fields []string, index map[int]int) error {
+	args := make([]int, len(fields)-1)
+	for i, field := range fields[1:] {
+		n, err := strconv.Atoi(field)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", field)
+		}
+		args[i] = n
+	}
+	load := func(id int) (int, error) {
+		node, ok := index[id]
+		if !ok {
+			return 0, fmt.Errorf("unknown load %d", id)
+		}
+		return node, nil
 	}
-	return sb.String()
-}
 
-// calculateCost computes the total cost of a solution
-func calculateCost(solution Solution) float64 {
-	totalDistance := 0.0
-	for _, route := range solution.routes {
-		previousNode := 0
-		for _, node := range route {
-			totalDistance += distanceMatrix[previousNode][node] + deliveryDistance[node-1]
-			previousNode = node
+	arity := map[string]int{"relocate": 2, "swap": 2, "2opt": 1, "eliminate": 1, "undo": 0, "show": 0, "help": 0}
+	want, ok := arity[fields[0]]
+	if !ok {
+		return fmt.Errorf("unknown command %q; type help for the list", fields[0])
+	}
+	if len(args) != want {
+		return fmt.Errorf("%s takes %d arguments, got %d", fields[0], want, len(args))
+	}
+
+	switch fields[0] {
+	case "relocate":
+		node, err := load(args[0])
+		if err != nil {
+			return err
+		}
+		return session.Relocate(node, args[1])
+	case "swap":
+		a, err := load(args[0])
+		if err != nil {
+			return err
+		}
+		b, err := load(args[1])
+		if err != nil {
+			return err
+		}
+		return session.Swap(a, b)
+	case "2opt":
+		return session.ImproveRoute(args[0])
+	case "eliminate":
+		return session.EliminateRoute(args[0])
+	case "undo":
+		if !session.Undo() {
+			return errors.New("nothing to undo")
 		}
-		totalDistance += distanceMatrix[previousNode][0]
 	}
-	return totalDistance + float64(len(solution.routes))*costPerDriver
+	return nil
 }
 
-// printSolution outputs the solution in the required format
-func printSolution(solution Solution) {
-	for _, route := range solution.routes {
-		fmt.Printf("[%s]\n", strings.Trim(strings.Join(strings.Fields(fmt.Sprint(route)), ","), "[]"))
+// printSession prints every route of the solution by number, with load IDs,
+// followed by its cost
+func printSession(w io.Writer, solution vrp.Solution, loads []vrp.Load, precision int) {
+	for i, route := range loadIDs(solution.Routes, loads) {
+		fmt.Fprintf(w, "route %d: %s time=%.*f\n", i, formatRoute(route), precision, solution.RouteTimes[i])
 	}
+	fmt.Fprintf(w, "drivers=%d total_cost=%.*f\n", len(solution.Routes), precision, solution.Cost)
 }
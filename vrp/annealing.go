@@ -0,0 +1,53 @@
+package vrp
+
+import (
+	"math"
+	"time"
+)
+
+// simulatedAnnealing implements Simulated Annealing over the same moves as the
+// tabu search, accepting worse solutions with probability exp(-delta/T)
+func (s *solver) simulatedAnnealing() Solution {
+	start := time.Now()
+
+	// Initialize a random initial solution
+	currentSolution := s.generateInitialSolution()
+	bestSolution := currentSolution
+	temperature := s.startTemperature
+	improvements := 0
+
+	iteration, lastImprovement := 0, 0
+	var stop string
+	for ; ; iteration++ {
+		if stop = s.stopReason(iteration, lastImprovement, start); stop != "" {
+			break
+		}
+		// Propose as many moves per iteration as the tabu search evaluates
+		for i := 0; i < s.neighborhoodSize; i++ {
+			candidate := s.randomMove(currentSolution)
+			if !s.isFeasible(candidate) {
+				continue
+			}
+			candidate.Cost = s.objective.Evaluate(candidate)
+
+			delta := candidate.Cost - currentSolution.Cost
+			if delta <= 0 || s.rng.Float64() < math.Exp(-delta/temperature) {
+				currentSolution = candidate
+			}
+			if currentSolution.Cost < bestSolution.Cost {
+				bestSolution = currentSolution
+				improvements++
+				lastImprovement = iteration
+				s.improved(bestSolution, iteration, start)
+			}
+		}
+
+		s.traceIteration(iteration, currentSolution.Cost, bestSolution.Cost)
+
+		// Geometric cooling schedule
+		temperature *= s.coolingRate
+	}
+
+	s.logf("stopped by %s after %d iterations: %d improving moves, best cost %.2f", stop, iteration, improvements, bestSolution.Cost)
+	return bestSolution
+}
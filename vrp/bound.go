@@ -0,0 +1,37 @@
+package vrp
+
+import "math"
+
+// LowerBound returns a simple lower bound on the cost of any solution to the
+// problem, useful for reporting the optimality gap of a solution
+func LowerBound(p *Problem, opts Options) (float64, error) {
+	s, err := newSolver(p, opts)
+	if err != nil {
+		return 0, err
+	}
+	return s.lowerBound(), nil
+}
+
+// lowerBound sums every delivery, since each load has to be driven from pickup
+// to dropoff, plus the cost of the fewest drivers that time could fit into
+func (s *solver) lowerBound() float64 {
+	return s.totalDelivery()*s.distanceWeight + float64(s.minDrivers())*s.costPerDriver
+}
+
+// totalDelivery sums the pickup-to-dropoff distance of every load
+func (s *solver) totalDelivery() float64 {
+	total := 0.0
+	for _, delivery := range s.deliveryDistance {
+		total += delivery
+	}
+	return total
+}
+
+// minDrivers is the fewest drivers whose shifts could hold every delivery
+func (s *solver) minDrivers() int {
+	drivers := int(math.Ceil(s.totalDelivery() / s.shiftTime))
+	if drivers == 0 && len(s.loads) > 0 {
+		drivers = 1
+	}
+	return drivers
+}
@@ -0,0 +1,39 @@
+package vrp
+
+import "sync"
+
+// costCacheSize bounds the number of cached solution costs
+const costCacheSize = 10000
+
+// costCache memoizes solution costs by neighborKey. It is safe for use by the
+// concurrent neighbor evaluation workers.
+type costCache struct {
+	mu    sync.Mutex
+	costs map[string]float64
+}
+
+// newCostCache creates an empty cost cache
+func newCostCache() *costCache {
+	return &costCache{costs: make(map[string]float64)}
+}
+
+// get returns the cached cost for a key
+func (c *costCache) get(key string) (float64, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	cost, ok := c.costs[key]
+	return cost, ok
+}
+
+// put stores the cost for a key, evicting an arbitrary entry once the cache is full
+func (c *costCache) put(key string, cost float64) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if _, ok := c.costs[key]; !ok && len(c.costs) >= costCacheSize {
+		for k := range c.costs {
+			delete(c.costs, k)
+			break
+		}
+	}
+	c.costs[key] = cost
+}
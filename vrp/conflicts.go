@@ -0,0 +1,68 @@
+package vrp
+
+import "fmt"
+
+// resolveConflicts maps the load IDs of the forbidden pairs to route indices
+// and rejects unknown loads and pairs that a pinned group would violate
+func (s *solver) resolveConflicts(conflicts [][2]int) error {
+	index := make(map[int]int, len(s.loads))
+	for i, load := range s.loads {
+		index[load.ID] = i + 1
+	}
+
+	s.conflicts = nil
+	if len(conflicts) == 0 {
+		return nil
+	}
+	s.conflicts = make([][]int, len(s.loads)+1)
+	for _, pair := range conflicts {
+		a, ok := index[pair[0]]
+		if !ok {
+			return fmt.Errorf("conflict references unknown load %d", pair[0])
+		}
+		b, ok := index[pair[1]]
+		if !ok {
+			return fmt.Errorf("conflict references unknown load %d", pair[1])
+		}
+		if a == b {
+			return fmt.Errorf("load %d cannot conflict with itself", pair[0])
+		}
+		if s.groupOf != nil && s.groupOf[a] != 0 && s.groupOf[a] == s.groupOf[b] {
+			return fmt.Errorf("loads %d and %d conflict but are pinned to the same group", pair[0], pair[1])
+		}
+		s.conflicts[a] = append(s.conflicts[a], b)
+		s.conflicts[b] = append(s.conflicts[b], a)
+	}
+	return nil
+}
+
+// conflictsWith reports whether a load may not share a route with any load on it
+func (s *solver) conflictsWith(node int, route []int) bool {
+	if s.conflicts == nil {
+		return false
+	}
+	for _, other := range s.conflicts[node] {
+		for _, routeNode := range route {
+			if routeNode == other {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// routeConflict returns the IDs of a forbidden pair of loads sharing the
+// route, or zeros when the route has none
+func (s *solver) routeConflict(route []int) (int, int) {
+	if s.conflicts == nil {
+		return 0, 0
+	}
+	for i, node := range route {
+		for _, other := range route[i+1:] {
+			if s.conflictsWith(other, []int{node}) {
+				return s.loads[node-1].ID, s.loads[other-1].ID
+			}
+		}
+	}
+	return 0, 0
+}
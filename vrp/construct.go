@@ -0,0 +1,440 @@
+package vrp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+)
+
+// minSelectionDistance caps the weight selectNextNode gives to a load whose
+// pickup coincides with the current position
+const minSelectionDistance = 1e-6
+
+// nodeSelector picks the next load to append to a route under construction,
+// returning its position in remainingLoads, or -1 when no remaining load can be added
+type nodeSelector func(route, remainingLoads []int, routeTime, routeDemand float64) int
+
+// validateInit reports an error for an unknown construction heuristic
+func validateInit(init string) error {
+	switch init {
+	case "", "random", "greedy", "insertion", "sequential", "savings":
+		return nil
+	}
+	return fmt.Errorf("unknown initial solution %q", init)
+}
+
+// generateInitialSolution creates an initial solution with the selected construction heuristic
+func (s *solver) generateInitialSolution() Solution {
+	if s.restartFrom != nil {
+		solution := *s.restartFrom
+		s.restartFrom = nil
+		s.logf("initial solution from the elite pool: %d routes, cost %.2f", len(solution.Routes), solution.Cost)
+		return solution
+	}
+	var solution Solution
+	switch s.init {
+	case "greedy":
+		solution = s.constructRoutes(s.selectNearestNode)
+	case "insertion":
+		solution = s.cheapestInsertion()
+	case "sequential":
+		solution = s.constructRoutes(s.selectSequentialNode)
+	case "savings":
+		solution = s.savings()
+	default:
+		solution = s.constructRoutes(s.selectNextNode)
+	}
+	if s.maxDrivers > 0 && len(solution.Routes) > s.maxDrivers {
+		solution = s.fitDriverLimit(solution)
+	}
+	s.logf("initial solution: %d routes, cost %.2f", len(solution.Routes), solution.Cost)
+	return solution
+}
+
+// fitDriverLimit empties routes, trying the smallest first, until the solution
+// has no more than maxDrivers routes or no route can be emptied
+func (s *solver) fitDriverLimit(solution Solution) Solution {
+	for len(solution.Routes) > s.maxDrivers {
+		order := make([]int, len(solution.Routes))
+		for i := range order {
+			order[i] = i
+		}
+		sort.SliceStable(order, func(a, b int) bool {
+			return len(solution.Routes[order[a]]) < len(solution.Routes[order[b]])
+		})
+		eliminated := false
+		for _, i := range order {
+			if reduced, ok := s.eliminateRouteAt(solution, i); ok {
+				solution, eliminated = reduced, true
+				break
+			}
+		}
+		if !eliminated {
+			s.logf("construction needs %d routes, more than the %d drivers allowed", len(solution.Routes), s.maxDrivers)
+			break
+		}
+	}
+	if s.allowDrops && len(solution.Routes) > s.maxDrivers {
+		solution = s.dropRoutes(solution)
+	}
+	return solution
+}
+
+// dropRoutes drops whole routes, those whose loads are cheapest to drop
+// first, until at most maxDrivers remain, then reinserts what it can of the
+// dropped loads, highest priority first
+func (s *solver) dropRoutes(solution Solution) Solution {
+	routes := cloneRoutes(solution.Routes)
+	penalty := func(route []int) float64 {
+		return s.dropCost(Solution{Dropped: route})
+	}
+	sort.SliceStable(routes, func(a, b int) bool { return penalty(routes[a]) < penalty(routes[b]) })
+	var dropped []int
+	for _, route := range routes[:len(routes)-s.maxDrivers] {
+		dropped = append(dropped, route...)
+	}
+	routes = routes[len(routes)-s.maxDrivers:]
+
+	sort.SliceStable(dropped, func(a, b int) bool {
+		return s.loads[dropped[a]-1].Priority > s.loads[dropped[b]-1].Priority
+	})
+	remaining := dropped
+	for _, node := range dropped {
+		segment := s.unit(node)
+		if !containsAll(remaining, segment) {
+			continue // Already reinserted with its group
+		}
+		if reinserted, ok := s.insertSegment(routes, segment); ok {
+			routes = reinserted
+			remaining = withoutLoads(remaining, segment)
+		}
+	}
+	sort.Ints(remaining)
+	s.logf("dropped %d loads to fit within %d drivers", len(remaining), s.maxDrivers)
+	reduced := Solution{Routes: routes, Dropped: append(append([]int(nil), solution.Dropped...), remaining...)}
+	sort.Ints(reduced.Dropped)
+	reduced.Cost = s.objective.Evaluate(reduced)
+	return reduced
+}
+
+// containsAll reports whether every one of loads is among nodes
+func containsAll(nodes, loads []int) bool {
+	return len(withoutLoads(loads, nodes)) == 0
+}
+
+// constructRoutes builds routes one at a time, appending the load chosen by
+// selectNext, together with the rest of its group, until it returns -1 and
+// then starting a new route
+func (s *solver) constructRoutes(selectNext nodeSelector) Solution {
+	var solution Solution
+	remainingLoads := make([]int, len(s.loads))
+	position := make([]int, len(s.loads)+1) // Index of each load in remainingLoads
+	for i := range remainingLoads {
+		remainingLoads[i] = i + 1
+		position[i+1] = i
+	}
+
+	// Create routes until all loads are assigned
+	for len(remainingLoads) > 0 {
+		var route []int
+		currentNode := 0
+		routeTime := 0.0
+		routeDemand := 0.0
+
+		// Build a single route
+		for len(remainingLoads) > 0 {
+			next := selectNext(route, remainingLoads, routeTime, routeDemand)
+			if next < 0 {
+				if len(route) > 0 {
+					break
+				}
+				// No load fits even on an empty route, so give the next one a
+				// route of its own to guarantee construction terminates
+				next = 0
+			}
+			for _, nextNode := range s.unit(remainingLoads[next]) {
+				route = append(route, nextNode)
+				var distance float64
+				distance, routeDemand = s.travel(currentNode, nextNode, routeDemand)
+				routeTime, _ = s.arrive(routeTime, distance, nextNode)
+				routeTime += s.deliveryDistance[nextNode-1]
+				currentNode = nextNode
+				remainingLoads = removeLoadAt(remainingLoads, position, position[nextNode])
+			}
+		}
+		s.logRouteClosed(route, remainingLoads, routeTime, routeDemand)
+
+		if len(route) > 0 {
+			solution.Routes = append(solution.Routes, route)
+		}
+	}
+
+	solution.Cost = s.objective.Evaluate(solution)
+	return solution
+}
+
+// removeLoadAt removes the load at index i of remainingLoads in O(1) by moving
+// the last load into its place, and updates that load's index in position
+func removeLoadAt(remainingLoads, position []int, i int) []int {
+	last := len(remainingLoads) - 1
+	remainingLoads[i] = remainingLoads[last]
+	position[remainingLoads[i]] = i
+	return remainingLoads[:last]
+}
+
+// lastNode returns the last load of a route, or 0 for the depot when it is empty
+func lastNode(route []int) int {
+	if len(route) == 0 {
+		return 0
+	}
+	return route[len(route)-1]
+}
+
+// canAppend reports whether a load, with the rest of its group, can be appended
+// to a route without breaking a time window, the shift limit, capacity, the
+// load limit or a conflict
+func (s *solver) canAppend(route []int, load int, routeTime, routeDemand float64) bool {
+	return s.appendViolation(route, load, routeTime, routeDemand) == ""
+}
+
+// appendViolation names the constraint that prevents appending a load, with the
+// rest of its group, to a route, or returns "" when the load can be appended
+func (s *solver) appendViolation(route []int, load int, routeTime, routeDemand float64) string {
+	unit := s.unit(load)
+	if s.maxLoads > 0 && len(route)+len(unit) > s.maxLoads {
+		return "max loads"
+	}
+	for _, node := range unit {
+		if s.conflictsWith(node, route) {
+			return "conflict"
+		}
+	}
+	currentNode := lastNode(route)
+	for _, node := range unit {
+		var distance float64
+		distance, routeDemand = s.travel(currentNode, node, routeDemand)
+		arrival, onTime := s.arrive(routeTime, distance, node)
+		if !onTime {
+			return "time window"
+		}
+		routeTime = arrival + s.deliveryDistance[node-1]
+		currentNode = node
+	}
+	if routeTime+s.returnLeg(currentNode) > s.shiftTime {
+		return "shift limit"
+	}
+	if s.capacity > 0 && routeDemand > s.capacity {
+		return "capacity"
+	}
+	return ""
+}
+
+// logRouteClosed reports, in verbose mode, which constraints rejected the
+// remaining loads when a route under construction had to be closed
+func (s *solver) logRouteClosed(route, remainingLoads []int, routeTime, routeDemand float64) {
+	if s.log == nil || len(remainingLoads) == 0 {
+		return
+	}
+	rejected := make(map[string]int)
+	for _, load := range remainingLoads {
+		if violation := s.appendViolation(route, load, routeTime, routeDemand); violation != "" {
+			rejected[violation]++
+		}
+	}
+	var reasons []string
+	for _, constraint := range []string{"shift limit", "time window", "capacity", "max loads", "conflict"} {
+		if rejected[constraint] > 0 {
+			reasons = append(reasons, fmt.Sprintf("%d by %s", rejected[constraint], constraint))
+		}
+	}
+	s.logf("route closed after %d loads: %d candidates rejected (%s)", len(route), len(remainingLoads), strings.Join(reasons, ", "))
+}
+
+// selectNextNode chooses the next load to add to a route at random, favoring nearby loads
+func (s *solver) selectNextNode(route, remainingLoads []int, routeTime, routeDemand float64) int {
+	currentNode := lastNode(route)
+	var probabilities []float64
+	var sum float64
+
+	// Calculate probabilities for each remaining load
+	for _, load := range remainingLoads {
+		if !s.canAppend(route, load, routeTime, routeDemand) {
+			probabilities = append(probabilities, 0)
+		} else {
+			// Coincident points would give an infinite weight, so clamp the
+			// distance to keep every probability finite
+			probability := 1.0 / math.Max(s.distanceMatrix[currentNode][load], minSelectionDistance)
+			probabilities = append(probabilities, probability)
+			sum += probability
+		}
+	}
+
+	if sum == 0 {
+		return -1
+	}
+
+	// Select a load based on the calculated probabilities
+	randomValue := s.rng.Float64() * sum
+	for i, probability := range probabilities {
+		randomValue -= probability
+		if randomValue <= 0 {
+			return i
+		}
+	}
+
+	return -1
+}
+
+// selectNearestNode deterministically chooses the closest feasible load
+func (s *solver) selectNearestNode(route, remainingLoads []int, routeTime, routeDemand float64) int {
+	currentNode := lastNode(route)
+	nearest := -1
+	nearestDistance := math.Inf(1)
+	for i, load := range remainingLoads {
+		if !s.canAppend(route, load, routeTime, routeDemand) {
+			continue
+		}
+		if distance := s.distanceMatrix[currentNode][load]; distance < nearestDistance {
+			nearest, nearestDistance = i, distance
+		}
+	}
+	return nearest
+}
+
+// selectSequentialNode chooses the remaining load that comes first in the
+// input, or -1 when it does not fit, so routes pack loads in input order
+func (s *solver) selectSequentialNode(route, remainingLoads []int, routeTime, routeDemand float64) int {
+	first := 0
+	for i, load := range remainingLoads {
+		if load < remainingLoads[first] {
+			first = i
+		}
+	}
+	if !s.canAppend(route, remainingLoads[first], routeTime, routeDemand) {
+		return -1
+	}
+	return first
+}
+
+// cheapestInsertion builds routes by repeatedly inserting the unassigned load,
+// together with the rest of its group, whose best feasible position across all
+// open routes adds the least cost. Opening a new route costs its round trip
+// plus the driver cost.
+func (s *solver) cheapestInsertion() Solution {
+	var solution Solution
+	remainingLoads := make([]int, len(s.loads))
+	position := make([]int, len(s.loads)+1) // Index of each load in remainingLoads
+	for i := range remainingLoads {
+		remainingLoads[i] = i + 1
+		position[i+1] = i
+	}
+
+	for len(remainingLoads) > 0 {
+		bestRoute := -1
+		var bestSegment, bestTarget []int
+		bestDelta := math.Inf(1)
+		for _, load := range remainingLoads {
+			segment := s.unit(load)
+			for r, route := range solution.Routes {
+				target, ok := s.bestInsertion(route, segment)
+				if !ok {
+					continue
+				}
+				if delta := s.routeCost(target) - s.routeCost(route); delta < bestDelta {
+					bestRoute, bestSegment, bestTarget, bestDelta = r, segment, target, delta
+				}
+			}
+			if delta := s.routeCost(segment) + s.costPerDriver; delta < bestDelta {
+				bestRoute, bestSegment, bestTarget, bestDelta = -1, segment, append([]int(nil), segment...), delta
+			}
+		}
+
+		if bestRoute < 0 {
+			solution.Routes = append(solution.Routes, bestTarget)
+		} else {
+			solution.Routes[bestRoute] = bestTarget
+		}
+		for _, load := range bestSegment {
+			remainingLoads = removeLoadAt(remainingLoads, position, position[load])
+		}
+	}
+
+	solution.Cost = s.objective.Evaluate(solution)
+	return solution
+}
+
+// saving is the distance saved by driving from the dropoff of one load
+// straight to the pickup of another instead of via the depot
+type saving struct {
+	from, to int
+	value    float64
+}
+
+// savings builds routes with the Clarke-Wright savings heuristic. It starts
+// with one route per load, or per pinned group, and considers joining the end
+// of one route to the start of another in decreasing order of the distance
+// saved, d(i,depot)+d(depot,j)-d(i,j), making every join that stays feasible.
+// With open routes d(i,depot) is zero, since no route returns to the depot.
+// A join also saves a driver, so joins that add less distance than the driver
+// cost are made too.
+func (s *solver) savings() Solution {
+	var routes [][]int
+	routeOf := make([]int, len(s.loads)+1)
+	for node := 1; node <= len(s.loads); node++ {
+		unit := s.unit(node)
+		if unit[0] != node {
+			continue // A group gets a single route, started from its first load
+		}
+		for _, member := range unit {
+			routeOf[member] = len(routes)
+		}
+		routes = append(routes, append([]int(nil), unit...))
+	}
+
+	var savings []saving
+	for i := 1; i <= len(s.loads); i++ {
+		for j := 1; j <= len(s.loads); j++ {
+			if i == j {
+				continue
+			}
+			value := s.returnLeg(i) + s.distanceMatrix[0][j] - s.distanceMatrix[i][j]
+			if value*s.distanceWeight+s.costPerDriver > 0 {
+				savings = append(savings, saving{i, j, value})
+			}
+		}
+	}
+	sort.Slice(savings, func(a, b int) bool {
+		if savings[a].value != savings[b].value {
+			return savings[a].value > savings[b].value
+		}
+		if savings[a].from != savings[b].from {
+			return savings[a].from < savings[b].from
+		}
+		return savings[a].to < savings[b].to
+	})
+
+	for _, sv := range savings {
+		a, b := routeOf[sv.from], routeOf[sv.to]
+		if a == b || lastNode(routes[a]) != sv.from || routes[b][0] != sv.to {
+			continue
+		}
+		merged := append(append([]int(nil), routes[a]...), routes[b]...)
+		if !s.routeFeasible(merged) {
+			continue
+		}
+		for _, node := range routes[b] {
+			routeOf[node] = a
+		}
+		routes[a], routes[b] = merged, nil
+	}
+
+	var solution Solution
+	for _, route := range routes {
+		if route != nil {
+			solution.Routes = append(solution.Routes, route)
+		}
+	}
+	solution.Cost = s.objective.Evaluate(solution)
+	return solution
+}
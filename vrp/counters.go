@@ -0,0 +1,39 @@
+package vrp
+
+import (
+	"fmt"
+	"strings"
+	"sync/atomic"
+)
+
+// Move operators tallied in counters.moves, in the order randomMove picks them
+const (
+	moveSwapRoutes = iota
+	moveIntra
+	moveRelocate
+	moveOrOpt
+	moveSwapLoads
+	moveDropOrReinsert
+	numMoves
+)
+
+// moveNames labels the move operators when the counters are logged
+var moveNames = [numMoves]string{"swap-routes", "intra", "relocate", "or-opt", "swap-loads", "drop-reinsert"}
+
+// counters counts the work done during a Solve call. The counters are atomic
+// because neighbors are evaluated by concurrent workers.
+type counters struct {
+	costCalls atomic.Int64 // Calls to calculateCost
+	cacheHits atomic.Int64 // Candidates whose cost came from the cost cache
+	moves     [numMoves]atomic.Int64
+}
+
+// String summarizes the counters on one line
+func (c *counters) String() string {
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "calculateCost called %d times, %d cache hits; moves:", c.costCalls.Load(), c.cacheHits.Load())
+	for i, name := range moveNames {
+		fmt.Fprintf(&sb, " %s=%d", name, c.moves[i].Load())
+	}
+	return sb.String()
+}
@@ -0,0 +1,80 @@
+package vrp
+
+import "sort"
+
+// LoadMove records a load that is on a different route in one solution than
+// in another. Route numbers index each solution's own routes, and -1 means
+// the load is on no route, e.g. because it was dropped.
+type LoadMove struct {
+	Load int
+	From int
+	To   int
+}
+
+// MovedLoads compares two sets of routes and returns the loads that changed
+// route, sorted by load. Routes carry no identity, so each route of b is first
+// paired with the route of a it shares the most loads with, and a load only
+// counts as moved when its two routes are not paired.
+func MovedLoads(a, b [][]int) []LoadMove {
+	routeA, routeB := routeOf(a), routeOf(b)
+
+	// Count the loads every pair of routes shares
+	shared := make(map[[2]int]int)
+	for load, i := range routeA {
+		if j, ok := routeB[load]; ok {
+			shared[[2]int{i, j}]++
+		}
+	}
+	pairs := make([][2]int, 0, len(shared))
+	for pair := range shared {
+		pairs = append(pairs, pair)
+	}
+	// Pair greedily by most shared loads, breaking ties by route number so
+	// the pairing never depends on map order
+	sort.Slice(pairs, func(x, y int) bool {
+		if shared[pairs[x]] != shared[pairs[y]] {
+			return shared[pairs[x]] > shared[pairs[y]]
+		}
+		if pairs[x][0] != pairs[y][0] {
+			return pairs[x][0] < pairs[y][0]
+		}
+		return pairs[x][1] < pairs[y][1]
+	})
+	pairedA, pairedB := make(map[int]int), make(map[int]bool)
+	for _, pair := range pairs {
+		if _, ok := pairedA[pair[0]]; ok || pairedB[pair[1]] {
+			continue
+		}
+		pairedA[pair[0]] = pair[1]
+		pairedB[pair[1]] = true
+	}
+
+	var moves []LoadMove
+	for load, i := range routeA {
+		j, ok := routeB[load]
+		if !ok {
+			j = -1
+		}
+		if to, paired := pairedA[i]; !paired || to != j {
+			moves = append(moves, LoadMove{Load: load, From: i, To: j})
+		}
+	}
+	for load, j := range routeB {
+		if _, ok := routeA[load]; !ok {
+			moves = append(moves, LoadMove{Load: load, From: -1, To: j})
+		}
+	}
+	sort.Slice(moves, func(x, y int) bool { return moves[x].Load < moves[y].Load })
+	return moves
+}
+
+// routeOf maps every load on the routes to the number of its route
+func routeOf(routes [][]int) map[int]int {
+	index := make(map[int]int)
+	for i, route := range routes {
+		for _, load := range route {
+			index[load] = i
+		}
+	}
+	return index
+}
@@ -0,0 +1,51 @@
+package vrp
+
+import (
+	"strconv"
+	"strings"
+)
+
+// ParseError reports malformed input. Line is the 1-based line of the input
+// the error was found on, or 0 when the input has no meaningful lines, as for
+// JSON.
+type ParseError struct {
+	Line   int
+	Reason string
+	Err    error // Underlying error, if any
+}
+
+// Error describes the problem, prefixed with its line when known
+func (e *ParseError) Error() string {
+	if e.Line == 0 {
+		return e.Reason
+	}
+	return "error parsing line " + strconv.Itoa(e.Line) + ": " + e.Reason
+}
+
+// Unwrap returns the underlying error, so errors.Is sees through a ParseError
+func (e *ParseError) Unwrap() error {
+	return e.Err
+}
+
+// InfeasibleError reports a problem that cannot be solved under its
+// constraints. LoadIDs lists the loads at fault, and is empty when the
+// problem as a whole is infeasible.
+type InfeasibleError struct {
+	LoadIDs []int
+	Reason  string
+}
+
+// Error describes the problem, listing the loads at fault
+func (e *InfeasibleError) Error() string {
+	if len(e.LoadIDs) == 0 {
+		return e.Reason
+	}
+	ids := make([]string, len(e.LoadIDs))
+	for i, id := range e.LoadIDs {
+		ids[i] = strconv.Itoa(id)
+	}
+	if len(ids) == 1 {
+		return "load " + ids[0] + " " + e.Reason
+	}
+	return "loads " + strings.Join(ids, ", ") + " " + e.Reason
+}
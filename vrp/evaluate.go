@@ -0,0 +1,74 @@
+package vrp
+
+import (
+	"math"
+	"sync"
+)
+
+// evaluateNeighbors fills in the cost of every candidate using a pool of
+// s.workers workers. Infeasible candidates get a cost of +Inf. With a single
+// worker the candidates are evaluated sequentially on the calling goroutine.
+//
+// Workers only read the distance matrices and the candidates' routes, none of
+// which change during evaluation. The cost cache is the only shared state they
+// write, and it is guarded by its own mutex. The solver's RNG is never touched
+// here; results are written back by index so the outcome does not depend on
+// scheduling.
+func (s *solver) evaluateNeighbors(candidates []Solution) {
+	type result struct {
+		index int
+		cost  float64
+	}
+
+	if s.workers <= 1 {
+		for i := range candidates {
+			candidates[i].Cost = s.evaluateCandidate(candidates[i])
+		}
+		return
+	}
+
+	workers := s.workers
+	if workers > len(candidates) {
+		workers = len(candidates)
+	}
+
+	jobs := make(chan int)
+	results := make(chan result, len(candidates))
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results <- result{i, s.evaluateCandidate(candidates[i])}
+			}
+		}()
+	}
+
+	for i := range candidates {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+	close(results)
+
+	for r := range results {
+		candidates[r.index].Cost = r.cost
+	}
+}
+
+// evaluateCandidate returns the cost of a candidate, or +Inf when it is
+// infeasible. Revisited solutions reuse their cached cost.
+func (s *solver) evaluateCandidate(candidate Solution) float64 {
+	key := neighborKey(candidate)
+	if cost, ok := s.cache.get(key); ok {
+		s.counters.cacheHits.Add(1)
+		return cost
+	}
+	cost := math.Inf(1)
+	if s.isFeasible(candidate) {
+		cost = s.objective.Evaluate(candidate)
+	}
+	s.cache.put(key, cost)
+	return cost
+}
@@ -0,0 +1,78 @@
+package vrp
+
+// edge is a directed leg driven between two nodes, 0 being the depot
+type edge [2]int
+
+// solutionEdges returns every leg the routes of a solution drive between
+// deliveries, including the legs from and back to the depot. Open routes
+// never drive back, so their return legs are left out.
+func (s *solver) solutionEdges(solution Solution) []edge {
+	var edges []edge
+	for _, route := range solution.Routes {
+		previousNode := 0
+		for _, node := range route {
+			edges = append(edges, edge{previousNode, node})
+			previousNode = node
+		}
+		if !s.openRoutes {
+			edges = append(edges, edge{previousNode, 0})
+		}
+	}
+	return edges
+}
+
+// resetPenalties clears the guided local search penalties before a search,
+// leaving them disabled when guided local search is off
+func (s *solver) resetPenalties() {
+	s.penalties, s.penaltyWeight = nil, 0
+	if s.guided {
+		s.penalties = make(map[edge]int)
+	}
+}
+
+// augmentedCost is the cost a guided local search compares neighbors by: the
+// solution's cost plus the weighted penalties of the edges it drives. Without
+// guided local search it is the cost itself.
+func (s *solver) augmentedCost(solution Solution) float64 {
+	if len(s.penalties) == 0 {
+		return solution.Cost
+	}
+	total := 0
+	for _, e := range s.solutionEdges(solution) {
+		total += s.penalties[e]
+	}
+	return solution.Cost + s.penaltyWeight*float64(total)
+}
+
+// penalize raises by one the penalty of the edges of a local optimum with the
+// highest utility, their distance divided by one plus their penalty, so long
+// edges are penalized first but no edge is penalized forever. The penalty
+// weight is set at the first local optimum from its average edge distance.
+func (s *solver) penalize(solution Solution) {
+	edges := s.solutionEdges(solution)
+	if len(edges) == 0 {
+		return
+	}
+	if s.penaltyWeight == 0 {
+		total := 0.0
+		for _, e := range edges {
+			total += s.distanceMatrix[e[0]][e[1]]
+		}
+		s.penaltyWeight = penaltyWeightFactor * total / float64(len(edges))
+	}
+
+	best := -1.0
+	var worst []edge
+	for _, e := range edges {
+		utility := s.distanceMatrix[e[0]][e[1]] / float64(1+s.penalties[e])
+		switch {
+		case utility > best:
+			best, worst = utility, []edge{e}
+		case utility == best:
+			worst = append(worst, e)
+		}
+	}
+	for _, e := range worst {
+		s.penalties[e]++
+	}
+}
@@ -0,0 +1,75 @@
+package vrp
+
+import "fmt"
+
+// resolveGroups maps the load IDs of the pinned groups to route indices and
+// rejects unknown loads and loads that appear in more than one group
+func (s *solver) resolveGroups(groups [][]int) error {
+	index := make(map[int]int, len(s.loads))
+	for i, load := range s.loads {
+		index[load.ID] = i + 1
+	}
+
+	s.groupOf = make([]int, len(s.loads)+1)
+	s.groups = nil
+	for g, group := range groups {
+		var nodes []int
+		for _, id := range group {
+			node, ok := index[id]
+			if !ok {
+				return fmt.Errorf("group %d references unknown load %d", g+1, id)
+			}
+			if s.groupOf[node] != 0 {
+				return fmt.Errorf("load %d appears in more than one group", id)
+			}
+			s.groupOf[node] = len(s.groups) + 1
+			nodes = append(nodes, node)
+		}
+		s.groups = append(s.groups, nodes)
+	}
+	return nil
+}
+
+// unit returns the loads that must travel with a load: its whole group in
+// the order given, or just the load itself when it is not pinned
+func (s *solver) unit(node int) []int {
+	if s.groupOf == nil || s.groupOf[node] == 0 {
+		return []int{node}
+	}
+	return s.groups[s.groupOf[node]-1]
+}
+
+// splitGroup returns the ID of a load whose group is only partly on the route,
+// or 0 when every group on the route is complete
+func (s *solver) splitGroup(route []int) int {
+	if len(s.groups) == 0 {
+		return 0
+	}
+	counts := make(map[int]int)
+	for _, node := range route {
+		if g := s.groupOf[node]; g != 0 {
+			counts[g]++
+		}
+	}
+	for _, node := range route {
+		if g := s.groupOf[node]; g != 0 && counts[g] != len(s.groups[g-1]) {
+			return s.loads[node-1].ID
+		}
+	}
+	return 0
+}
+
+// checkGroupsFit returns an error for the first group that cannot be
+// delivered on a single route in the order it was given
+func (s *solver) checkGroupsFit() error {
+	for g, group := range s.groups {
+		if !s.routeFeasible(group) {
+			ids := make([]int, len(group))
+			for i, node := range group {
+				ids[i] = s.loads[node-1].ID
+			}
+			return &InfeasibleError{LoadIDs: ids, Reason: fmt.Sprintf("of group %d cannot be delivered on a single route in the order given", g+1)}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,31 @@
+package vrp
+
+import (
+	"encoding/binary"
+	"fmt"
+	"hash/fnv"
+	"math"
+	"sort"
+)
+
+// InstanceHash returns a stable FNV-1a hash of the problem's loads, as 16 hex
+// digits. Loads are hashed in ID order, so the hash identifies the instance
+// regardless of the order of lines in its file.
+func InstanceHash(p *Problem) string {
+	loads := append([]Load(nil), p.Loads...)
+	sort.Slice(loads, func(i, j int) bool { return loads[i].ID < loads[j].ID })
+
+	h := fnv.New64a()
+	var buf [8]byte
+	write := func(v uint64) {
+		binary.LittleEndian.PutUint64(buf[:], v)
+		h.Write(buf[:])
+	}
+	for _, l := range loads {
+		write(uint64(int64(l.ID)))
+		for _, v := range []float64{l.Pickup[0], l.Pickup[1], l.Dropoff[0], l.Dropoff[1], l.Demand, l.ReadyTime, l.DueTime, l.Priority} {
+			write(math.Float64bits(v + 0)) // Adding zero folds -0 into 0
+		}
+	}
+	return fmt.Sprintf("%016x", h.Sum64())
+}
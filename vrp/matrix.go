@@ -0,0 +1,270 @@
+package vrp
+
+import (
+	"fmt"
+	"math"
+	"reflect"
+	"sync"
+)
+
+// earthRadiusKm is the mean Earth radius used by HaversineDistance
+const earthRadiusKm = 6371.0
+
+// parallelMatrixLoads is the instance size from which the distance matrix is
+// computed by several goroutines; below it the overhead outweighs the gain
+const parallelMatrixLoads = 500
+
+// DistanceFunc computes the distance between two points. It may be called
+// from several goroutines at once, so it must not modify shared state.
+type DistanceFunc func(a, b [2]float64) float64
+
+// metrics maps metric names to their distance functions
+var metrics = map[string]DistanceFunc{
+	"euclidean": EuclideanDistance,
+	"manhattan": ManhattanDistance,
+	"chebyshev": ChebyshevDistance,
+	"haversine": HaversineDistance,
+}
+
+// MetricByName returns the distance function registered under the given name
+func MetricByName(name string) (DistanceFunc, error) {
+	distance, ok := metrics[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown metric %q", name)
+	}
+	return distance, nil
+}
+
+// isHaversine reports whether a distance function is HaversineDistance
+func isHaversine(distance DistanceFunc) bool {
+	return reflect.ValueOf(distance).Pointer() == reflect.ValueOf(HaversineDistance).Pointer()
+}
+
+// checkGeographic returns an error naming the first point that is not a
+// (latitude, longitude) in degrees, which HaversineDistance needs to be
+// meaningful. Cartesian data fed to it would otherwise go unnoticed.
+func (s *solver) checkGeographic() error {
+	for i, depot := range s.depots {
+		if !isLatLon(depot) {
+			return fmt.Errorf("depot %d (%g,%g) is not a (latitude,longitude) with latitude in [-90,90] and longitude in [-180,180]", i, depot[0], depot[1])
+		}
+	}
+	for _, load := range s.loads {
+		for _, point := range []struct {
+			name string
+			at   [2]float64
+		}{{"pickup", load.Pickup}, {"dropoff", load.Dropoff}} {
+			if !isLatLon(point.at) {
+				return fmt.Errorf("load %d: %s (%g,%g) is not a (latitude,longitude) with latitude in [-90,90] and longitude in [-180,180]", load.ID, point.name, point.at[0], point.at[1])
+			}
+		}
+	}
+	return nil
+}
+
+// isLatLon reports whether a point is a latitude and longitude in degrees
+func isLatLon(point [2]float64) bool {
+	return point[0] >= -90 && point[0] <= 90 && point[1] >= -180 && point[1] <= 180
+}
+
+// initializeMatrices precomputes distance matrices for efficiency. Index 0
+// stands for the depot: with several depots it holds the distance from the
+// nearest depot to each pickup and from each dropoff to its nearest depot.
+// A non-nil matrix replaces the computed distances entirely.
+func (s *solver) initializeMatrices(matrix [][]float64) error {
+	if matrix != nil {
+		return s.loadMatrix(matrix)
+	}
+	totalLoads := len(s.loads)
+	s.deliveryDistance = make([]float64, totalLoads)
+	s.distanceMatrix = make([][]float64, totalLoads+1)
+
+	for i := range s.distanceMatrix {
+		s.distanceMatrix[i] = make([]float64, totalLoads+1)
+	}
+	// A reload detour must unload at one depot, which the nearest depot at
+	// either end of the detour need not be
+	s.reloadMatrix = nil
+	if s.allowReload && s.capacity > 0 && len(s.depots) > 1 {
+		s.reloadMatrix = make([][]float64, totalLoads+1)
+		for i := 1; i <= totalLoads; i++ {
+			s.reloadMatrix[i] = make([]float64, totalLoads+1)
+		}
+	}
+
+	distance := s.distance
+	if s.normalize {
+		distance = s.normalizedDistance()
+	}
+
+	// Each load only writes its own row, its own column of the depot row and
+	// its own delivery distance, so rows can be filled concurrently
+	if s.workers <= 1 || totalLoads < parallelMatrixLoads {
+		for i := range s.loads {
+			s.fillMatrixRow(i, distance)
+		}
+		return nil
+	}
+	var wg sync.WaitGroup
+	for w := 0; w < s.workers; w++ {
+		wg.Add(1)
+		go func(w int) {
+			defer wg.Done()
+			for i := w; i < totalLoads; i += s.workers {
+				s.fillMatrixRow(i, distance)
+			}
+		}(w)
+	}
+	wg.Wait()
+	return nil
+}
+
+// fillMatrixRow computes the distances from load i's dropoff to every pickup
+// and the depot, from the depot to its pickup, its delivery distance and, when
+// needed, its reload detours to every pickup
+func (s *solver) fillMatrixRow(i int, distance DistanceFunc) {
+	load := s.loads[i]
+	s.deliveryDistance[i] = distance(load.Pickup, load.Dropoff)
+	s.distanceMatrix[0][i+1] = math.Inf(1)
+	s.distanceMatrix[i+1][0] = math.Inf(1)
+	for _, depot := range s.depots {
+		s.distanceMatrix[0][i+1] = math.Min(s.distanceMatrix[0][i+1], distance(depot, load.Pickup))
+		s.distanceMatrix[i+1][0] = math.Min(s.distanceMatrix[i+1][0], distance(load.Dropoff, depot))
+	}
+	for j, otherLoad := range s.loads {
+		if i != j {
+			s.distanceMatrix[i+1][j+1] = distance(load.Dropoff, otherLoad.Pickup)
+		}
+	}
+	if s.reloadMatrix == nil {
+		return
+	}
+	for j, otherLoad := range s.loads {
+		s.reloadMatrix[i+1][j+1] = math.Inf(1)
+		for _, depot := range s.depots {
+			detour := distance(load.Dropoff, depot) + distance(depot, otherLoad.Pickup)
+			s.reloadMatrix[i+1][j+1] = math.Min(s.reloadMatrix[i+1][j+1], detour)
+		}
+	}
+}
+
+// normalizedDistance wraps the distance metric so it is evaluated on
+// coordinates translated to put the first depot at the origin and scaled into
+// [-1, 1], then scaled back. The result is in the original units, but large
+// coordinate magnitudes never reach the metric. This is exact only for metrics
+// that are translation invariant and scale linearly, which rules out haversine.
+func (s *solver) normalizedDistance() DistanceFunc {
+	origin := s.depots[0]
+	scale := 0.0
+	extend := func(point [2]float64) {
+		scale = math.Max(scale, math.Max(math.Abs(point[0]-origin[0]), math.Abs(point[1]-origin[1])))
+	}
+	for _, depot := range s.depots {
+		extend(depot)
+	}
+	for _, load := range s.loads {
+		extend(load.Pickup)
+		extend(load.Dropoff)
+	}
+	if scale == 0 {
+		scale = 1
+	}
+
+	normalize := func(point [2]float64) [2]float64 {
+		return [2]float64{(point[0] - origin[0]) / scale, (point[1] - origin[1]) / scale}
+	}
+	return func(a, b [2]float64) float64 {
+		return s.distance(normalize(a), normalize(b)) * scale
+	}
+}
+
+// checkMatrix returns an error naming the first distance that is NaN or
+// infinite, since one would silently turn every cost it touches non-finite
+func (s *solver) checkMatrix() error {
+	for i, delivery := range s.deliveryDistance {
+		if !isFinite(delivery) {
+			return fmt.Errorf("distance matrix: delivery distance of load %d is %v", s.loads[i].ID, delivery)
+		}
+	}
+	for i, row := range s.distanceMatrix {
+		for j, distance := range row {
+			if !isFinite(distance) {
+				return fmt.Errorf("distance matrix: distance from %s to %s is %v", s.matrixNode(i, "dropoff"), s.matrixNode(j, "pickup"), distance)
+			}
+		}
+	}
+	return nil
+}
+
+// matrixNode describes matrix index i, naming the given stop of its load
+func (s *solver) matrixNode(i int, stop string) string {
+	if i == 0 {
+		return "the depot"
+	}
+	return fmt.Sprintf("the %s of load %d", stop, s.loads[i-1].ID)
+}
+
+// isFinite reports whether x is neither NaN nor infinite
+func isFinite(x float64) bool {
+	return !math.IsNaN(x) && !math.IsInf(x, 0)
+}
+
+// loadMatrix copies a precomputed, possibly asymmetric, distance matrix in
+// the layout documented on Options.Matrix after checking its dimensions
+func (s *solver) loadMatrix(matrix [][]float64) error {
+	size := len(s.loads) + 1
+	if len(matrix) != size {
+		return fmt.Errorf("distance matrix has %d rows, expected %d", len(matrix), size)
+	}
+	s.deliveryDistance = make([]float64, len(s.loads))
+	s.distanceMatrix = make([][]float64, size)
+	for i, row := range matrix {
+		if len(row) != size {
+			return fmt.Errorf("distance matrix row %d has %d columns, expected %d", i, len(row), size)
+		}
+		s.distanceMatrix[i] = append([]float64(nil), row...)
+		// The diagonal holds each load's own pickup-to-dropoff distance
+		if i > 0 {
+			s.deliveryDistance[i-1] = row[i]
+			s.distanceMatrix[i][i] = 0
+		}
+	}
+	return nil
+}
+
+// EuclideanDistance calculates the Euclidean distance between two points
+func EuclideanDistance(a, b [2]float64) float64 {
+	dx, dy := a[0]-b[0], a[1]-b[1]
+	return math.Sqrt(dx*dx + dy*dy)
+}
+
+// ManhattanDistance calculates the L1 distance between two points
+func ManhattanDistance(a, b [2]float64) float64 {
+	return math.Abs(a[0]-b[0]) + math.Abs(a[1]-b[1])
+}
+
+// ChebyshevDistance calculates the L-infinity distance between two points, the
+// larger of the two axis distances
+func ChebyshevDistance(a, b [2]float64) float64 {
+	return math.Max(math.Abs(a[0]-b[0]), math.Abs(a[1]-b[1]))
+}
+
+// WeightedDistance returns a Euclidean metric that scales the x and y axis
+// distances by wx and wy before combining them
+func WeightedDistance(wx, wy float64) DistanceFunc {
+	return func(a, b [2]float64) float64 {
+		dx, dy := wx*(a[0]-b[0]), wy*(a[1]-b[1])
+		return math.Sqrt(dx*dx + dy*dy)
+	}
+}
+
+// HaversineDistance calculates the great-circle distance in kilometers between
+// two (latitude, longitude) points given in degrees. It is only meaningful for
+// geographic coordinates; applied to Cartesian data it returns garbage.
+func HaversineDistance(a, b [2]float64) float64 {
+	lat1, lat2 := a[0]*math.Pi/180, b[0]*math.Pi/180
+	dLat := lat2 - lat1
+	dLon := (b[1] - a[1]) * math.Pi / 180
+	h := math.Sin(dLat/2)*math.Sin(dLat/2) + math.Cos(lat1)*math.Cos(lat2)*math.Sin(dLon/2)*math.Sin(dLon/2)
+	return 2 * earthRadiusKm * math.Asin(math.Min(1, math.Sqrt(h)))
+}
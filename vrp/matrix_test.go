@@ -0,0 +1,83 @@
+package vrp
+
+import (
+	"math"
+	"testing"
+)
+
+func TestEuclideanDistance(t *testing.T) {
+	tests := []struct {
+		name string
+		a, b [2]float64
+		want float64
+	}{
+		{"same point", [2]float64{3, -4}, [2]float64{3, -4}, 0},
+		{"origin", [2]float64{0, 0}, [2]float64{0, 0}, 0},
+		{"along x", [2]float64{-2, 1}, [2]float64{5, 1}, 7},
+		{"along y", [2]float64{1, 6}, [2]float64{1, -3}, 9},
+		{"diagonal", [2]float64{0, 0}, [2]float64{3, 4}, 5},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := EuclideanDistance(tt.a, tt.b); got != tt.want {
+				t.Errorf("EuclideanDistance(%v, %v) = %g, want %g", tt.a, tt.b, got, tt.want)
+			}
+			if got := EuclideanDistance(tt.b, tt.a); got != tt.want {
+				t.Errorf("EuclideanDistance(%v, %v) = %g, want %g", tt.b, tt.a, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestInitializeMatrices(t *testing.T) {
+	loads := []Load{
+		{ID: 1, Pickup: [2]float64{3, 0}, Dropoff: [2]float64{3, 4}},
+		{ID: 2, Pickup: [2]float64{0, -5}, Dropoff: [2]float64{0, -2}},
+		{ID: 3, Pickup: [2]float64{-6, 8}, Dropoff: [2]float64{0, 8}},
+	}
+	s, err := newSolver(&Problem{Loads: loads}, Options{Workers: 1})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got := len(s.distanceMatrix); got != len(loads)+1 {
+		t.Fatalf("matrix has %d rows, want %d", got, len(loads)+1)
+	}
+	depot := [2]float64{0, 0}
+	for i, load := range loads {
+		if got, want := s.deliveryDistance[i], EuclideanDistance(load.Pickup, load.Dropoff); got != want {
+			t.Errorf("deliveryDistance[%d] = %g, want %g", i, got, want)
+		}
+		if got, want := s.distanceMatrix[0][i+1], EuclideanDistance(depot, load.Pickup); got != want {
+			t.Errorf("distanceMatrix[0][%d] = %g, want depot to pickup %g", i+1, got, want)
+		}
+		if got, want := s.distanceMatrix[i+1][0], EuclideanDistance(load.Dropoff, depot); got != want {
+			t.Errorf("distanceMatrix[%d][0] = %g, want dropoff to depot %g", i+1, got, want)
+		}
+		for j, next := range loads {
+			if i == j {
+				continue
+			}
+			if got, want := s.distanceMatrix[i+1][j+1], EuclideanDistance(load.Dropoff, next.Pickup); got != want {
+				t.Errorf("distanceMatrix[%d][%d] = %g, want dropoff to pickup %g", i+1, j+1, got, want)
+			}
+		}
+	}
+
+	// The hand-computed values of the layout above
+	for _, c := range []struct {
+		from, to int
+		want     float64
+	}{
+		{0, 1, 3}, {0, 2, 5}, {0, 3, 10}, {1, 0, 5}, {2, 0, 2}, {3, 0, 8}, {1, 2, math.Sqrt(90)}, {2, 1, math.Sqrt(13)},
+	} {
+		if got := s.distanceMatrix[c.from][c.to]; math.Abs(got-c.want) > 1e-9 {
+			t.Errorf("distanceMatrix[%d][%d] = %g, want %g", c.from, c.to, got, c.want)
+		}
+	}
+	for i, want := range []float64{4, 3, 6} {
+		if got := s.deliveryDistance[i]; got != want {
+			t.Errorf("deliveryDistance[%d] = %g, want %g", i, got, want)
+		}
+	}
+}
@@ -0,0 +1,429 @@
+package vrp
+
+import (
+	"math"
+	"sort"
+)
+
+// randomMove applies a randomly chosen neighborhood move to the solution
+func (s *solver) randomMove(solution Solution) Solution {
+	if s.allowDrops && s.rng.Intn(dropMoveOdds) == 0 {
+		s.counters.moves[moveDropOrReinsert].Add(1)
+		return s.dropOrReinsert(solution)
+	}
+	moves := []func(Solution) Solution{s.swapRandomRoutes, s.twoOptRandomRoute, s.relocate, s.orOpt, s.swapLoads}
+	move := s.rng.Intn(len(moves))
+	s.counters.moves[move].Add(1)
+	moved := moves[move](solution)
+	// Route moves leave the dropped loads alone
+	moved.Dropped = solution.Dropped
+	return moved
+}
+
+// dropOrReinsert creates a new solution by either dropping a random load,
+// with the rest of its group, or reinserting a random dropped one at its
+// cheapest feasible position. A reinserted load that fits nowhere gets a
+// route of its own when MaxDrivers allows one.
+func (s *solver) dropOrReinsert(solution Solution) Solution {
+	newSolution := Solution{Routes: cloneRoutes(solution.Routes), Dropped: solution.Dropped}
+	if len(solution.Dropped) > 0 && (len(solution.Routes) == 0 || s.rng.Intn(2) == 0) {
+		segment := s.unit(solution.Dropped[s.rng.Intn(len(solution.Dropped))])
+		routes, ok := s.insertSegment(newSolution.Routes, segment)
+		if !ok {
+			return newSolution
+		}
+		newSolution.Routes = routes
+		newSolution.Dropped = withoutLoads(solution.Dropped, segment)
+		return newSolution
+	}
+	if len(solution.Routes) == 0 {
+		return newSolution
+	}
+
+	r := s.rng.Intn(len(newSolution.Routes))
+	route := newSolution.Routes[r]
+	segment := s.unit(route[s.rng.Intn(len(route))])
+	newSolution.Routes[r] = withoutLoads(route, segment)
+	if len(newSolution.Routes[r]) == 0 {
+		newSolution.Routes = append(newSolution.Routes[:r], newSolution.Routes[r+1:]...)
+	}
+	newSolution.Dropped = append(append([]int(nil), solution.Dropped...), segment...)
+	sort.Ints(newSolution.Dropped)
+	return newSolution
+}
+
+// insertSegment inserts a segment at its cheapest feasible position across
+// the routes, or on a new route when it fits nowhere and MaxDrivers allows,
+// and reports false when neither is possible
+func (s *solver) insertSegment(routes [][]int, segment []int) ([][]int, bool) {
+	bestRoute := -1
+	var bestTarget []int
+	bestDelta := math.Inf(1)
+	for i, route := range routes {
+		target, ok := s.bestInsertion(route, segment)
+		if !ok {
+			continue
+		}
+		if delta := s.routeTime(target) - s.routeTime(route); delta < bestDelta {
+			bestRoute, bestTarget, bestDelta = i, target, delta
+		}
+	}
+	if bestRoute >= 0 {
+		routes[bestRoute] = bestTarget
+		return routes, true
+	}
+	if (s.maxDrivers > 0 && len(routes) >= s.maxDrivers) || !s.routeFeasible(segment) {
+		return routes, false
+	}
+	return append(routes, append([]int(nil), segment...)), true
+}
+
+// withoutLoads returns a new slice of the nodes that are not in loads
+func withoutLoads(nodes, loads []int) []int {
+	result := make([]int, 0, len(nodes))
+	for _, node := range nodes {
+		removed := false
+		for _, load := range loads {
+			if node == load {
+				removed = true
+				break
+			}
+		}
+		if !removed {
+			result = append(result, node)
+		}
+	}
+	return result
+}
+
+// perturb applies perturbationMoves random moves to the solution, skipping any
+// that would make it infeasible, and returns it with its cost
+func (s *solver) perturb(solution Solution) Solution {
+	for i := 0; i < perturbationMoves; i++ {
+		if candidate := s.randomMove(solution); s.isFeasible(candidate) {
+			solution = candidate
+		}
+	}
+	solution.Cost = s.objective.Evaluate(solution)
+	return solution
+}
+
+// swapRandomRoutes creates a new solution by swapping two random routes
+func (s *solver) swapRandomRoutes(solution Solution) Solution {
+	// Deep-copy the solution and swap routes
+	var newSolution Solution
+	newSolution.Routes = cloneRoutes(solution.Routes)
+
+	if len(newSolution.Routes) < 2 {
+		return newSolution
+	}
+
+	i, j := s.rng.Intn(len(newSolution.Routes)), s.rng.Intn(len(newSolution.Routes))
+	for i == j {
+		j = s.rng.Intn(len(newSolution.Routes))
+	}
+
+	newSolution.Routes[i], newSolution.Routes[j] = newSolution.Routes[j], newSolution.Routes[i]
+
+	return newSolution
+}
+
+// twoOptRandomRoute creates a new solution by applying the intra-route
+// optimization, 2-opt by default, to a random route
+func (s *solver) twoOptRandomRoute(solution Solution) Solution {
+	if len(solution.Routes) == 0 {
+		return Solution{Routes: cloneRoutes(solution.Routes)}
+	}
+	return s.improveRouteAt(solution, s.rng.Intn(len(solution.Routes)))
+}
+
+// improveRouteAt creates a new solution by applying the intra-route
+// optimization to the route at index
+func (s *solver) improveRouteAt(solution Solution, index int) Solution {
+	var newSolution Solution
+	newSolution.Routes = cloneRoutes(solution.Routes)
+	newSolution.Routes[index] = s.improveRoute(newSolution.Routes[index])
+	return newSolution
+}
+
+// twoOpt reverses route segments while doing so shortens the route and keeps
+// it feasible, returning a new slice
+func (s *solver) twoOpt(route []int) []int {
+	best := append([]int(nil), route...)
+	bestTime := s.routeTime(best)
+
+	for improved := true; improved; {
+		improved = false
+		for i := 0; i < len(best)-1; i++ {
+			for j := i + 1; j < len(best); j++ {
+				candidate := append([]int(nil), best...)
+				reverse(candidate[i : j+1])
+				if candidateTime := s.routeTime(candidate); candidateTime < bestTime && s.routeFeasible(candidate) {
+					best, bestTime = candidate, candidateTime
+					improved = true
+				}
+			}
+		}
+	}
+
+	return best
+}
+
+// improveRoute reorders a route with the selected intra-route optimization,
+// returning a new slice
+func (s *solver) improveRoute(route []int) []int {
+	switch s.intra {
+	case "3opt", "lk":
+		return s.threeOpt(route)
+	}
+	return s.twoOpt(route)
+}
+
+// threeOpt alternates 2-opt with 3-opt segment moves, which move a chain of up
+// to maxSegmentLength loads, as is or reversed, to another position in the
+// route, until neither shortens the route. It returns a new slice and keeps
+// the route feasible.
+func (s *solver) threeOpt(route []int) []int {
+	best := s.twoOpt(route)
+	for {
+		moved, ok := s.moveSegment(best)
+		if !ok {
+			return best
+		}
+		best = s.twoOpt(moved)
+	}
+}
+
+// moveSegment returns the route after the first feasible segment move that
+// shortens it, and false when there is none
+func (s *solver) moveSegment(route []int) ([]int, bool) {
+	routeTime := s.routeTime(route)
+	for length := 1; length <= maxSegmentLength && length < len(route); length++ {
+		for i := 0; i+length <= len(route); i++ {
+			rest := removeAt(route, i, length)
+			for _, reversed := range []bool{false, true} {
+				segment := append([]int(nil), route[i:i+length]...)
+				if reversed {
+					reverse(segment)
+				}
+				for k := 0; k <= len(rest); k++ {
+					if k == i {
+						continue // Same position, which 2-opt already covers reversed
+					}
+					candidate := append(append(append(make([]int, 0, len(route)), rest[:k]...), segment...), rest[k:]...)
+					if s.routeTime(candidate) < routeTime && s.routeFeasible(candidate) {
+						return candidate, true
+					}
+				}
+			}
+		}
+	}
+	return nil, false
+}
+
+// relocate creates a new solution by moving a random load from one route to
+// the best feasible position in another random route
+func (s *solver) relocate(solution Solution) Solution {
+	if len(solution.Routes) < 2 {
+		return Solution{Routes: cloneRoutes(solution.Routes)}
+	}
+
+	from, to := s.rng.Intn(len(solution.Routes)), s.rng.Intn(len(solution.Routes))
+	for from == to {
+		to = s.rng.Intn(len(solution.Routes))
+	}
+	moved, _ := s.relocateAt(solution, from, s.rng.Intn(len(solution.Routes[from])), to)
+	return moved
+}
+
+// relocateAt creates a new solution by moving the load at pos of route from
+// to the best feasible position in route to. It reports false, with the
+// routes unchanged, when the load fits nowhere in that route.
+func (s *solver) relocateAt(solution Solution, from, pos, to int) (Solution, bool) {
+	var newSolution Solution
+	newSolution.Routes = cloneRoutes(solution.Routes)
+
+	source := newSolution.Routes[from]
+	target, ok := s.bestInsertion(newSolution.Routes[to], source[pos:pos+1])
+	if !ok {
+		return newSolution, false
+	}
+
+	newSolution.Routes[to] = target
+	newSolution.Routes[from] = removeAt(source, pos, 1)
+	// Drop the source route entirely once its last load has moved
+	if len(newSolution.Routes[from]) == 0 {
+		newSolution.Routes = append(newSolution.Routes[:from], newSolution.Routes[from+1:]...)
+	}
+
+	return newSolution, true
+}
+
+// orOpt creates a new solution by moving a chain of 2 or 3 consecutive loads,
+// in order, to the best feasible position in any route
+func (s *solver) orOpt(solution Solution) Solution {
+	var newSolution Solution
+	newSolution.Routes = cloneRoutes(solution.Routes)
+
+	if len(newSolution.Routes) == 0 {
+		return newSolution
+	}
+
+	from := s.rng.Intn(len(newSolution.Routes))
+	source := newSolution.Routes[from]
+	if len(source) < 2 {
+		return newSolution
+	}
+	length := 2 + s.rng.Intn(2)
+	if length > len(source) {
+		length = len(source)
+	}
+	pos := s.rng.Intn(len(source) - length + 1)
+	segment := source[pos : pos+length]
+	remaining := removeAt(source, pos, length)
+
+	// Find the route where inserting the chain adds the least time
+	bestRoute := -1
+	var bestTarget []int
+	bestDelta := math.Inf(1)
+	for i, route := range newSolution.Routes {
+		if i == from {
+			route = remaining
+		}
+		target, ok := s.bestInsertion(route, segment)
+		if !ok {
+			continue
+		}
+		if delta := s.routeTime(target) - s.routeTime(route); delta < bestDelta {
+			bestRoute, bestTarget, bestDelta = i, target, delta
+		}
+	}
+	if bestRoute < 0 {
+		return newSolution
+	}
+
+	newSolution.Routes[from] = remaining
+	newSolution.Routes[bestRoute] = bestTarget
+	// Drop the source route entirely once its last load has moved
+	if len(newSolution.Routes[from]) == 0 {
+		newSolution.Routes = append(newSolution.Routes[:from], newSolution.Routes[from+1:]...)
+	}
+
+	return newSolution
+}
+
+// swapLoads creates a new solution by exchanging a random load between two
+// random routes, keeping the original solution when either route becomes infeasible
+func (s *solver) swapLoads(solution Solution) Solution {
+	if len(solution.Routes) < 2 {
+		return Solution{Routes: cloneRoutes(solution.Routes)}
+	}
+
+	i, j := s.rng.Intn(len(solution.Routes)), s.rng.Intn(len(solution.Routes))
+	for i == j {
+		j = s.rng.Intn(len(solution.Routes))
+	}
+	a, b := s.rng.Intn(len(solution.Routes[i])), s.rng.Intn(len(solution.Routes[j]))
+	swapped, _ := s.swapLoadsAt(solution, i, a, j, b)
+	return swapped
+}
+
+// swapLoadsAt creates a new solution by exchanging the load at position a of
+// route i with the load at position b of route j. It reports false, with the
+// routes unchanged, when either route becomes infeasible.
+func (s *solver) swapLoadsAt(solution Solution, i, a, j, b int) (Solution, bool) {
+	var newSolution Solution
+	newSolution.Routes = cloneRoutes(solution.Routes)
+
+	first := append([]int(nil), newSolution.Routes[i]...)
+	second := append([]int(nil), newSolution.Routes[j]...)
+	first[a], second[b] = second[b], first[a]
+	if !s.routeFeasible(first) || !s.routeFeasible(second) {
+		return newSolution, false
+	}
+
+	newSolution.Routes[i], newSolution.Routes[j] = first, second
+	return newSolution, true
+}
+
+// eliminateRoute tries to empty the route with the fewest loads, as
+// eliminateRouteAt does
+func (s *solver) eliminateRoute(solution Solution) (Solution, bool) {
+	if len(solution.Routes) < 2 {
+		return solution, false
+	}
+	smallest := 0
+	for i, route := range solution.Routes {
+		if len(route) < len(solution.Routes[smallest]) {
+			smallest = i
+		}
+	}
+	return s.eliminateRouteAt(solution, smallest)
+}
+
+// eliminateRouteAt tries to empty the route at index by inserting each of its
+// loads, group by group, at the cheapest feasible position of the other
+// routes. It returns the solution without that route and true on success, or
+// the original solution and false when some load does not fit.
+func (s *solver) eliminateRouteAt(solution Solution, index int) (Solution, bool) {
+	if len(solution.Routes) < 2 {
+		return solution, false
+	}
+	routes := cloneRoutes(solution.Routes)
+	source := routes[index]
+	routes = append(routes[:index], routes[index+1:]...)
+	placed := make(map[int]bool)
+	for _, node := range source {
+		if placed[node] {
+			continue
+		}
+		segment := s.unit(node)
+		bestRoute := -1
+		var bestTarget []int
+		bestDelta := math.Inf(1)
+		for i, route := range routes {
+			target, ok := s.bestInsertion(route, segment)
+			if !ok {
+				continue
+			}
+			if delta := s.routeTime(target) - s.routeTime(route); delta < bestDelta {
+				bestRoute, bestTarget, bestDelta = i, target, delta
+			}
+		}
+		if bestRoute < 0 {
+			return solution, false
+		}
+		routes[bestRoute] = bestTarget
+		for _, member := range segment {
+			placed[member] = true
+		}
+	}
+
+	reduced := Solution{Routes: routes, Dropped: solution.Dropped}
+	reduced.Cost = s.objective.Evaluate(reduced)
+	return reduced, true
+}
+
+// cloneRoutes deep-copies routes so that a move never shares a route slice
+// with the solution it was derived from
+func cloneRoutes(routes [][]int) [][]int {
+	clone := make([][]int, len(routes))
+	for i, route := range routes {
+		clone[i] = append([]int(nil), route...)
+	}
+	return clone
+}
+
+// removeAt returns a new slice without the count nodes starting at pos
+func removeAt(route []int, pos, count int) []int {
+	result := make([]int, 0, len(route)-count)
+	result = append(result, route[:pos]...)
+	return append(result, route[pos+count:]...)
+}
+
+// reverse reverses a slice in place
+func reverse(nodes []int) {
+	for i, j := 0, len(nodes)-1; i < j; i, j = i+1, j-1 {
+		nodes[i], nodes[j] = nodes[j], nodes[i]
+	}
+}
@@ -0,0 +1,74 @@
+package vrp
+
+import (
+	"fmt"
+	"math"
+)
+
+// Objective scores a solution during the search; lower is better. Evaluate may
+// be called from several goroutines at once, so it must not modify shared state.
+type Objective interface {
+	Evaluate(Solution) float64
+}
+
+// ObjectiveFunc adapts an ordinary function to the Objective interface
+type ObjectiveFunc func(Solution) float64
+
+// Evaluate calls f(solution)
+func (f ObjectiveFunc) Evaluate(solution Solution) float64 {
+	return f(solution)
+}
+
+// newObjective returns the built-in objective registered under name
+func (s *solver) newObjective(name string) (Objective, error) {
+	switch name {
+	case "", "cost":
+		return ObjectiveFunc(s.balanceCost), nil
+	case "balance":
+		// The balance objective is the cost objective with a weighted spread
+		if s.balanceWeight == 0 {
+			s.balanceWeight = 1
+		}
+		return ObjectiveFunc(s.balanceCost), nil
+	case "slack":
+		return ObjectiveFunc(s.slackCost), nil
+	}
+	return nil, fmt.Errorf("unknown objective %q", name)
+}
+
+// customObjective wraps a caller's objective so it always sees RouteTimes
+func (s *solver) customObjective(objective Objective) Objective {
+	return ObjectiveFunc(func(solution Solution) float64 {
+		solution.RouteTimes = s.routeTimes(solution)
+		return objective.Evaluate(solution)
+	})
+}
+
+// balanceCost adds the balance term to the cost, favoring even workloads
+// across drivers. With a zero balance weight it is the cost itself.
+func (s *solver) balanceCost(solution Solution) float64 {
+	return s.calculateCost(solution) + s.balanceTerm(solution)
+}
+
+// balanceTerm is the spread between the longest and shortest route, in
+// minutes, scaled by the balance weight
+func (s *solver) balanceTerm(solution Solution) float64 {
+	if s.balanceWeight == 0 || len(solution.Routes) == 0 {
+		return 0
+	}
+	longest, shortest := math.Inf(-1), math.Inf(1)
+	for _, duration := range s.routeTimes(solution) {
+		longest, shortest = math.Max(longest, duration), math.Min(shortest, duration)
+	}
+	return s.balanceWeight * (longest - shortest)
+}
+
+// slackCost subtracts the slack left on the tightest route from the cost,
+// favoring solutions where no driver works right up to the shift limit
+func (s *solver) slackCost(solution Solution) float64 {
+	longest := 0.0
+	for _, duration := range s.routeTimes(solution) {
+		longest = math.Max(longest, duration)
+	}
+	return s.calculateCost(solution) - (s.shiftTime - longest) + s.balanceTerm(solution)
+}
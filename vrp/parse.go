@@ -0,0 +1,296 @@
+package vrp
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ReadLoads reads load data from the given reader
+func ReadLoads(r io.Reader) ([]Load, error) {
+	var loads []Load
+	seen := make(map[int]bool)
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := scanner.Text()
+		if strings.HasPrefix(line, "loadNumber") {
+			continue // Skip header line
+		}
+		if strings.TrimSpace(line) == "" {
+			continue // Skip empty lines
+		}
+		// Parse load data and add to loads slice
+		load, err := parseLoad(line)
+		if err != nil {
+			return nil, &ParseError{Line: lineNumber, Reason: err.Error(), Err: err}
+		}
+		if seen[load.ID] {
+			return nil, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("duplicate load id %d", load.ID)}
+		}
+		seen[load.ID] = true
+		loads = append(loads, load)
+	}
+	return loads, scanner.Err()
+}
+
+// jsonLoad is the JSON representation of a Load read by ReadLoadsJSON
+type jsonLoad struct {
+	ID        *int        `json:"id"`
+	Pickup    *[2]float64 `json:"pickup"`
+	Dropoff   *[2]float64 `json:"dropoff"`
+	Demand    float64     `json:"demand"`
+	ReadyTime float64     `json:"ready_time"`
+	DueTime   float64     `json:"due_time"`
+	Priority  float64     `json:"priority"`
+}
+
+// ReadLoadsJSON reads load data from a JSON document of the form
+// {"loads":[{"id":1,"pickup":[x,y],"dropoff":[x,y]}]}. Each load may also set
+// "demand", "ready_time", "due_time" and "priority".
+func ReadLoadsJSON(r io.Reader) ([]Load, error) {
+	var input struct {
+		Loads []jsonLoad `json:"loads"`
+	}
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&input); err != nil {
+		return nil, &ParseError{Reason: "error parsing JSON: " + err.Error(), Err: err}
+	}
+
+	loads := make([]Load, len(input.Loads))
+	seen := make(map[int]bool)
+	for i, l := range input.Loads {
+		switch {
+		case l.ID == nil:
+			return nil, &ParseError{Reason: fmt.Sprintf("load %d: missing id", i+1)}
+		case l.Pickup == nil:
+			return nil, &ParseError{Reason: fmt.Sprintf("load %d: missing pickup", *l.ID)}
+		case l.Dropoff == nil:
+			return nil, &ParseError{Reason: fmt.Sprintf("load %d: missing dropoff", *l.ID)}
+		case seen[*l.ID]:
+			return nil, &ParseError{Reason: fmt.Sprintf("duplicate load id %d", *l.ID)}
+		case l.Priority < -1:
+			return nil, &ParseError{Reason: fmt.Sprintf("load %d: priority %g is below -1", *l.ID, l.Priority)}
+		}
+		seen[*l.ID] = true
+		loads[i] = Load{
+			ID:        *l.ID,
+			Pickup:    *l.Pickup,
+			Dropoff:   *l.Dropoff,
+			Demand:    l.Demand,
+			ReadyTime: l.ReadyTime,
+			DueTime:   l.DueTime,
+			Priority:  l.Priority,
+		}
+	}
+	return loads, nil
+}
+
+// ReadRoutes reads solution routes in the "[1,2,3]" one-route-per-line format
+func ReadRoutes(r io.Reader) ([][]int, error) {
+	var routes [][]int
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" {
+			continue // Skip empty lines
+		}
+		if !strings.HasPrefix(line, "[") || !strings.HasSuffix(line, "]") {
+			return nil, &ParseError{Line: lineNumber, Reason: "expected a route like [1,2,3]"}
+		}
+		var route []int
+		for _, field := range strings.Split(strings.Trim(line, "[]"), ",") {
+			id, err := strconv.Atoi(strings.TrimSpace(field))
+			if err != nil {
+				return nil, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("invalid load id %q", field)}
+			}
+			route = append(route, id)
+		}
+		routes = append(routes, route)
+	}
+	return routes, scanner.Err()
+}
+
+// ReadGroups reads one group of load IDs per line, separated by whitespace,
+// commas or semicolons
+func ReadGroups(r io.Reader) ([][]int, error) {
+	var groups [][]int
+	scanner := bufio.NewScanner(r)
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := splitFields(scanner.Text())
+		if len(fields) == 0 {
+			continue // Skip empty lines
+		}
+		var group []int
+		for _, field := range fields {
+			id, err := strconv.Atoi(field)
+			if err != nil {
+				return nil, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("invalid load id %q", field)}
+			}
+			group = append(group, id)
+		}
+		groups = append(groups, group)
+	}
+	return groups, scanner.Err()
+}
+
+// ReadConflicts reads one pair of conflicting load IDs per line, in the same
+// format as ReadGroups
+func ReadConflicts(r io.Reader) ([][2]int, error) {
+	lines, err := ReadGroups(r)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := make([][2]int, len(lines))
+	for i, line := range lines {
+		if len(line) != 2 {
+			return nil, &ParseError{Reason: fmt.Sprintf("conflict %d: expected a pair of load ids, got %d", i+1, len(line))}
+		}
+		conflicts[i] = [2]int{line[0], line[1]}
+	}
+	return conflicts, nil
+}
+
+// ReadMatrix reads a distance matrix with one row per line and the values
+// separated by commas, semicolons or whitespace
+func ReadMatrix(r io.Reader) ([][]float64, error) {
+	var matrix [][]float64
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, 1<<24) // Rows of large instances exceed the default line limit
+	lineNumber := 0
+	for scanner.Scan() {
+		lineNumber++
+		fields := splitFields(scanner.Text())
+		if len(fields) == 0 {
+			continue // Skip empty lines
+		}
+		row := make([]float64, len(fields))
+		for i, field := range fields {
+			value, err := strconv.ParseFloat(field, 64)
+			if err != nil {
+				return nil, &ParseError{Line: lineNumber, Reason: fmt.Sprintf("invalid distance %q", field), Err: err}
+			}
+			row[i] = value
+		}
+		matrix = append(matrix, row)
+	}
+	return matrix, scanner.Err()
+}
+
+// HasSequentialIDs reports whether the load IDs are 1, 2, ..., n in order,
+// which is what the route indices in a Solution assume
+func HasSequentialIDs(loads []Load) bool {
+	for i, load := range loads {
+		if load.ID != i+1 {
+			return false
+		}
+	}
+	return true
+}
+
+// parseLoad converts a single data line into a Load
+func parseLoad(line string) (Load, error) {
+	parts := splitFields(line)
+	if len(parts) != 3 && len(parts) != 4 && len(parts) != 6 && len(parts) != 7 {
+		return Load{}, fmt.Errorf("expected 3, 4, 6 or 7 fields, got %d", len(parts))
+	}
+	id, err := strconv.Atoi(parts[0])
+	if err != nil {
+		return Load{}, fmt.Errorf("invalid load id %q: %w", parts[0], err)
+	}
+	pickup, err := ParseCoordinates(parts[1])
+	if err != nil {
+		return Load{}, fmt.Errorf("invalid pickup: %w", err)
+	}
+	dropoff, err := ParseCoordinates(parts[2])
+	if err != nil {
+		return Load{}, fmt.Errorf("invalid dropoff: %w", err)
+	}
+	load := Load{ID: id, Pickup: pickup, Dropoff: dropoff}
+
+	// The demand column is optional and defaults to zero
+	if len(parts) >= 4 {
+		if load.Demand, err = strconv.ParseFloat(parts[3], 64); err != nil {
+			return Load{}, fmt.Errorf("invalid demand %q: %w", parts[3], err)
+		}
+	}
+	// The time window columns are optional and default to no window
+	if len(parts) >= 6 {
+		if load.ReadyTime, err = strconv.ParseFloat(parts[4], 64); err != nil {
+			return Load{}, fmt.Errorf("invalid ready time %q: %w", parts[4], err)
+		}
+		if load.DueTime, err = strconv.ParseFloat(parts[5], 64); err != nil {
+			return Load{}, fmt.Errorf("invalid due time %q: %w", parts[5], err)
+		}
+	}
+	// The priority column is optional and defaults to zero
+	if len(parts) == 7 {
+		if load.Priority, err = strconv.ParseFloat(parts[6], 64); err != nil {
+			return Load{}, fmt.Errorf("invalid priority %q: %w", parts[6], err)
+		}
+		if load.Priority < -1 {
+			return Load{}, fmt.Errorf("priority %g is below -1", load.Priority)
+		}
+	}
+	return load, nil
+}
+
+// splitFields splits a line on whitespace, commas and semicolons, keeping
+// parenthesized coordinates such as "(12.3, 45.6)" together as a single field
+func splitFields(line string) []string {
+	var fields []string
+	var field strings.Builder
+	depth := 0
+	for _, r := range line {
+		switch {
+		case r == '(':
+			depth++
+		case r == ')' && depth > 0:
+			depth--
+		case isFieldSeparator(r) && depth == 0:
+			if field.Len() > 0 {
+				fields = append(fields, field.String())
+				field.Reset()
+			}
+			continue
+		}
+		field.WriteRune(r)
+	}
+	if field.Len() > 0 {
+		fields = append(fields, field.String())
+	}
+	return fields
+}
+
+// isFieldSeparator reports whether r separates columns of a data line
+func isFieldSeparator(r rune) bool {
+	return unicode.IsSpace(r) || r == ',' || r == ';'
+}
+
+// ParseCoordinates converts a coordinate such as "(x,y)" or "x,y" to a float64 pair
+func ParseCoordinates(coord string) ([2]float64, error) {
+	coord = strings.Trim(strings.TrimSpace(coord), "()")
+	parts := strings.Split(coord, ",")
+	if len(parts) != 2 {
+		return [2]float64{}, fmt.Errorf("expected coordinate in (x,y) format, got %q", coord)
+	}
+	x, err := strconv.ParseFloat(strings.TrimSpace(parts[0]), 64)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	y, err := strconv.ParseFloat(strings.TrimSpace(parts[1]), 64)
+	if err != nil {
+		return [2]float64{}, err
+	}
+	return [2]float64{x, y}, nil
+}
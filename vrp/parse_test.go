@@ -0,0 +1,38 @@
+package vrp
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func FuzzReadLoads(f *testing.F) {
+	files, err := filepath.Glob(filepath.Join("..", "Training", "*.txt"))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			f.Fatal(err)
+		}
+		f.Add(data)
+	}
+	f.Add([]byte("loadNumber pickup dropoff\n1 (1,2) (3,4) 5 0 60 2\n"))
+	f.Add([]byte("1;(1, 2);(3, 4)\n1 (5,6) (7,8)\n"))
+
+	f.Fuzz(func(t *testing.T, data []byte) {
+		loads, err := ReadLoads(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		seen := make(map[int]bool)
+		for _, load := range loads {
+			if seen[load.ID] {
+				t.Fatalf("load id %d read twice without an error", load.ID)
+			}
+			seen[load.ID] = true
+		}
+	})
+}
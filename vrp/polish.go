@@ -0,0 +1,79 @@
+package vrp
+
+// polish merges routes while that saves cost, then runs the intra-route
+// optimization to convergence on every route of the solution. A route is only
+// replaced when that does not increase its cost, which can happen when waiting
+// time is part of the objective, and the whole polish is dropped if it scores
+// worse, so polishing never makes the solution worse. Both 2-opt and 3-opt keep
+// every route feasible.
+func (s *solver) polish(solution Solution) Solution {
+	polished := Solution{Routes: s.mergeRoutes(cloneRoutes(solution.Routes)), Dropped: solution.Dropped}
+	for i, route := range polished.Routes {
+		if candidate := s.improveRoute(route); s.routeCost(candidate) <= s.routeCost(route) {
+			polished.Routes[i] = candidate
+		}
+	}
+	polished.Cost = s.objective.Evaluate(polished)
+	// Shorter routes can still score worse under objectives beyond cost
+	if polished.Cost > solution.Cost {
+		return solution
+	}
+	s.logf("polished solution: cost %.2f", polished.Cost)
+	return polished
+}
+
+// mergeRoutes repeatedly merges the pair of routes whose feasible combination
+// saves the most cost, counting the driver it frees, until no merge saves
+// anything
+func (s *solver) mergeRoutes(routes [][]int) [][]int {
+	for {
+		bestA, bestB := -1, -1
+		var bestMerged []int
+		bestSaving := 0.0
+		for a := range routes {
+			for b := a + 1; b < len(routes); b++ {
+				merged, ok := s.mergeRoutePair(routes[a], routes[b])
+				if !ok {
+					continue
+				}
+				saving := s.routeCost(routes[a]) + s.routeCost(routes[b]) + s.costPerDriver - s.routeCost(merged)
+				if saving > bestSaving {
+					bestA, bestB, bestMerged, bestSaving = a, b, merged, saving
+				}
+			}
+		}
+		if bestA < 0 {
+			return routes
+		}
+		s.logf("merged two routes, saving %.2f", bestSaving)
+		routes[bestA] = bestMerged
+		routes = append(routes[:bestB], routes[bestB+1:]...)
+	}
+}
+
+// mergeRoutePair returns the cheaper feasible way to serve two routes as one,
+// appending either route to the other and then reordering, and false when
+// neither way fits within the shift limit and other constraints
+func (s *solver) mergeRoutePair(a, b []int) ([]int, bool) {
+	// Every delivery is driven regardless of order, so skip pairs whose
+	// deliveries alone exceed the shift
+	delivery := 0.0
+	for _, node := range append(append([]int(nil), a...), b...) {
+		delivery += s.deliveryDistance[node-1]
+	}
+	if delivery > s.shiftTime {
+		return nil, false
+	}
+
+	var best []int
+	for _, merged := range [][]int{append(append([]int(nil), a...), b...), append(append([]int(nil), b...), a...)} {
+		merged = s.improveRoute(merged)
+		if !s.routeFeasible(merged) {
+			continue
+		}
+		if best == nil || s.routeCost(merged) < s.routeCost(best) {
+			best = merged
+		}
+	}
+	return best, best != nil
+}
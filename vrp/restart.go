@@ -0,0 +1,71 @@
+package vrp
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// restartSeed derives the seed of a restart so every restart explores a
+// different path while the whole run stays reproducible from one seed
+func restartSeed(seed int64, restart int) int64 {
+	return seed + int64(restart)*1000003
+}
+
+// runRestarts runs the search once per restart and returns the best solution
+// across all of them. Each restart starts from a fresh initial solution or,
+// with probability eliteRestartProb, from a perturbed elite solution. Every
+// restart draws from rng when it is given, and from its own seeded source
+// otherwise.
+func (s *solver) runRestarts(search func(*solver) Solution, seed int64, rng *rand.Rand, restarts int) Solution {
+	var best Solution
+	for r := 0; r < restarts; r++ {
+		s.rng = rng
+		if s.rng == nil {
+			s.rng = rand.New(rand.NewSource(restartSeed(seed, r)))
+		}
+		if len(s.elite) > 0 && s.eliteRestartProb > 0 && s.rng.Float64() < s.eliteRestartProb {
+			start := s.perturb(s.pickElite())
+			s.restartFrom = &start
+		}
+		solution := search(s)
+		if restarts > 1 {
+			s.logf("restart %d: cost %.2f", r, solution.Cost)
+			s.addElite(solution)
+		}
+		if r == 0 || solution.Cost < best.Cost {
+			best = solution
+		}
+	}
+	return best
+}
+
+// addElite adds a solution to the elite pool, keeping the eliteSize cheapest
+// distinct solutions
+func (s *solver) addElite(solution Solution) {
+	key := neighborKey(solution)
+	for _, elite := range s.elite {
+		if neighborKey(elite) == key {
+			return
+		}
+	}
+	i := sort.Search(len(s.elite), func(i int) bool { return s.elite[i].Cost > solution.Cost })
+	s.elite = append(s.elite, Solution{})
+	copy(s.elite[i+1:], s.elite[i:])
+	s.elite[i] = solution
+	if len(s.elite) > eliteSize {
+		s.elite = s.elite[:eliteSize]
+	}
+}
+
+// pickElite chooses an elite solution at random, weighting the i-th cheapest
+// of n by n-i so better solutions are seeded from more often
+func (s *solver) pickElite() Solution {
+	n := len(s.elite)
+	pick := s.rng.Intn(n * (n + 1) / 2)
+	for i := range s.elite {
+		if pick -= n - i; pick < 0 {
+			return s.elite[i]
+		}
+	}
+	return s.elite[0]
+}
@@ -0,0 +1,268 @@
+package vrp
+
+import (
+	"errors"
+	"fmt"
+	"math"
+)
+
+// routeTime computes the total time of a route: travel between stops, any
+// reload detours, every delivery and the return to the depot, unless routes
+// are open
+func (s *solver) routeTime(route []int) float64 {
+	total := 0.0
+	carried := 0.0
+	previousNode := 0
+	for _, node := range route {
+		var distance float64
+		distance, carried = s.travel(previousNode, node, carried)
+		total += distance + s.deliveryDistance[node-1]
+		previousNode = node
+	}
+	return total + s.returnLeg(previousNode)
+}
+
+// returnLeg is the distance from the last node of a route back to the depot,
+// or zero with open routes, where drivers end at their last dropoff
+func (s *solver) returnLeg(node int) float64 {
+	if s.openRoutes {
+		return 0
+	}
+	return s.distanceMatrix[node][0]
+}
+
+// travel returns the distance from a node to the pickup of a load and the
+// demand carried once the load is picked up. When reloads are allowed and the
+// load would exceed capacity, the vehicle detours via the depot to unload, so
+// the carried demand restarts from the load alone.
+func (s *solver) travel(from, load int, carried float64) (float64, float64) {
+	demand := s.loads[load-1].Demand
+	if s.reloads(from, load, carried) {
+		if s.reloadMatrix != nil {
+			return s.reloadMatrix[from][load], demand
+		}
+		return s.distanceMatrix[from][0] + s.distanceMatrix[0][load], demand
+	}
+	return s.distanceMatrix[from][load], carried + demand
+}
+
+// reloads reports whether the vehicle returns to the depot between a node and
+// the pickup of a load because the load would exceed capacity
+func (s *solver) reloads(from, load int, carried float64) bool {
+	return s.allowReload && s.capacity > 0 && from != 0 && carried+s.loads[load-1].Demand > s.capacity
+}
+
+// arrive advances the clock by travel to the pickup of a load, waiting until
+// the load's ready time if needed. It reports false when the due time is missed.
+func (s *solver) arrive(clock, travel float64, load int) (float64, bool) {
+	clock += travel
+	l := s.loads[load-1]
+	if l.DueTime > 0 && clock > l.DueTime {
+		return clock, false
+	}
+	return math.Max(clock, l.ReadyTime), true
+}
+
+// routeSchedule walks a route in time, returning its duration including any
+// waiting, the total waiting time, and whether every due time is met
+func (s *solver) routeSchedule(route []int) (duration, waiting float64, onTime bool) {
+	clock := 0.0
+	carried := 0.0
+	previousNode := 0
+	for _, node := range route {
+		var distance float64
+		distance, carried = s.travel(previousNode, node, carried)
+		travelled := clock + distance
+		var ok bool
+		clock, ok = s.arrive(clock, distance, node)
+		if !ok {
+			return clock, waiting, false
+		}
+		waiting += clock - travelled
+		clock += s.deliveryDistance[node-1]
+		previousNode = node
+	}
+	return clock + s.returnLeg(previousNode), waiting, true
+}
+
+// routeTimes returns the duration of every route of the solution, including waiting
+func (s *solver) routeTimes(solution Solution) []float64 {
+	times := make([]float64, len(solution.Routes))
+	for i, route := range solution.Routes {
+		times[i], _, _ = s.routeSchedule(route)
+	}
+	return times
+}
+
+// totalDistance returns the distance all routes of a solution travel
+func (s *solver) totalDistance(solution Solution) float64 {
+	total := 0.0
+	for _, route := range solution.Routes {
+		total += s.routeTime(route)
+	}
+	return total
+}
+
+// routeDemand returns the most demand the route carries between depot
+// visits: its total demand, or with reloads the largest demand of any trip
+func (s *solver) routeDemand(route []int) float64 {
+	most, carried := 0.0, 0.0
+	previousNode := 0
+	for _, node := range route {
+		_, carried = s.travel(previousNode, node, carried)
+		most = math.Max(most, carried)
+		previousNode = node
+	}
+	return most
+}
+
+// routeReloads counts the depot visits a route makes to reload mid-route
+func (s *solver) routeReloads(route []int) int {
+	count := 0
+	carried := 0.0
+	previousNode := 0
+	for _, node := range route {
+		if s.reloads(previousNode, node, carried) {
+			count++
+		}
+		_, carried = s.travel(previousNode, node, carried)
+		previousNode = node
+	}
+	return count
+}
+
+// checkRoutes warns, in verbose mode, about any route that would only work by
+// returning to the depot mid-route without reloads being allowed, and reports
+// the reload detours taken when they are
+func (s *solver) checkRoutes(solution Solution) {
+	reloads := 0
+	for i, route := range solution.Routes {
+		if violation := s.routeViolation(route); violation != "" {
+			s.logf("warning: route %d is infeasible: %s", i, violation)
+		}
+		reloads += s.routeReloads(route)
+	}
+	if s.allowReload {
+		s.logf("%d reloads at the depot across %d routes", reloads, len(solution.Routes))
+	}
+}
+
+// routeFeasible reports whether a single route fits within the shift limit,
+// vehicle capacity, load limit and load time windows without splitting a group
+// or pairing conflicting loads
+func (s *solver) routeFeasible(route []int) bool {
+	if s.maxLoads > 0 && len(route) > s.maxLoads {
+		return false
+	}
+	if s.splitGroup(route) != 0 {
+		return false
+	}
+	if a, _ := s.routeConflict(route); a != 0 {
+		return false
+	}
+	if s.capacity > 0 && s.routeDemand(route) > s.capacity {
+		return false
+	}
+	duration, _, onTime := s.routeSchedule(route)
+	return onTime && duration <= s.shiftTime
+}
+
+// isFeasible reports whether every route of the solution is feasible
+func (s *solver) isFeasible(solution Solution) bool {
+	for _, route := range solution.Routes {
+		if !s.routeFeasible(route) {
+			return false
+		}
+	}
+	return true
+}
+
+// bestInsertion inserts the segment, in order, at the position of the route that
+// adds the least time while staying feasible. It returns a new slice and false
+// when no feasible position exists.
+func (s *solver) bestInsertion(route, segment []int) ([]int, bool) {
+	var best []int
+	bestTime := math.Inf(1)
+	for pos := 0; pos <= len(route); pos++ {
+		candidate := make([]int, 0, len(route)+len(segment))
+		candidate = append(candidate, route[:pos]...)
+		candidate = append(candidate, segment...)
+		candidate = append(candidate, route[pos:]...)
+		if !s.routeFeasible(candidate) {
+			continue
+		}
+		if candidateTime := s.routeTime(candidate); candidateTime < bestTime {
+			best, bestTime = candidate, candidateTime
+		}
+	}
+	return best, best != nil
+}
+
+// checkLoadsFit returns an error listing every load that cannot be served
+// even on a route of its own, because its due time cannot be met straight
+// from the depot, its round trip exceeds the shift limit or its demand exceeds
+// capacity, since no feasible solution exists then
+func (s *solver) checkLoadsFit() error {
+	var late, long, heavy []int
+	for i, load := range s.loads {
+		duration, _, onTime := s.routeSchedule([]int{i + 1})
+		switch {
+		case !onTime:
+			late = append(late, load.ID)
+		case duration > s.shiftTime:
+			long = append(long, load.ID)
+		case s.capacity > 0 && load.Demand > s.capacity:
+			heavy = append(heavy, load.ID)
+		}
+	}
+	if len(late) > 0 {
+		return &InfeasibleError{LoadIDs: late, Reason: "cannot be reached before the due time even straight from the depot"}
+	}
+	if len(long) > 0 {
+		return &InfeasibleError{LoadIDs: long, Reason: fmt.Sprintf("cannot be delivered within the %.0f-minute shift limit", s.shiftTime)}
+	}
+	if len(heavy) > 0 {
+		return &InfeasibleError{LoadIDs: heavy, Reason: fmt.Sprintf("cannot be carried within the vehicle capacity of %g", s.capacity)}
+	}
+	return nil
+}
+
+// validateSolution confirms that every load is delivered exactly once, or
+// dropped when drops are allowed
+func (s *solver) validateSolution(solution Solution) error {
+	seen := make([]bool, len(s.loads)+1)
+	count := 0
+	if len(solution.Dropped) > 0 && !s.allowDrops {
+		return errors.New("loads are dropped but drops are not allowed")
+	}
+	for _, node := range solution.Dropped {
+		if node < 1 || node > len(s.loads) {
+			return fmt.Errorf("unknown load %d is dropped", node)
+		}
+		if seen[node] {
+			return fmt.Errorf("load %d is dropped more than once", node)
+		}
+		seen[node] = true
+		count++
+	}
+	for _, route := range solution.Routes {
+		for _, node := range route {
+			if node < 1 || node > len(s.loads) {
+				return fmt.Errorf("route references unknown load %d", node)
+			}
+			if seen[node] {
+				return fmt.Errorf("load %d is delivered more than once, or delivered and dropped", node)
+			}
+			seen[node] = true
+			count++
+		}
+	}
+	if count != len(s.loads) {
+		for node := 1; node <= len(s.loads); node++ {
+			if !seen[node] {
+				return fmt.Errorf("load %d is never delivered", node)
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,169 @@
+package vrp
+
+import (
+	"errors"
+	"fmt"
+	"math/rand"
+)
+
+// Session holds a solution that moves are applied to one at a time, for
+// exploring the neighborhood by hand with the operators the search uses.
+// Loads are identified by their 1-based index and routes by their position in
+// the current solution, as in Solution.Routes, while errors name loads by ID.
+type Session struct {
+	solver   *solver
+	solution Solution
+	history  []Solution // Solutions before each applied move, for Undo
+}
+
+// NewSession builds an initial solution with the constructor selected by
+// opts.Init and starts a session from it
+func NewSession(p *Problem, opts Options) (*Session, error) {
+	if p == nil {
+		return nil, errors.New("nil problem")
+	}
+	if err := validateInit(opts.Init); err != nil {
+		return nil, err
+	}
+
+	s, err := newSolver(p, opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := s.resolveGroups(opts.Groups); err != nil {
+		return nil, err
+	}
+	if err := s.resolveConflicts(opts.Conflicts); err != nil {
+		return nil, err
+	}
+	if err := s.checkLoadsFit(); err != nil {
+		return nil, err
+	}
+	if err := s.checkGroupsFit(); err != nil {
+		return nil, err
+	}
+	s.rng = opts.Rand
+	if s.rng == nil {
+		s.rng = rand.New(rand.NewSource(restartSeed(opts.Seed, 0)))
+	}
+	return &Session{solver: s, solution: s.generateInitialSolution()}, nil
+}
+
+// Solution returns the current solution with its cost, route times and distance
+func (s *Session) Solution() Solution {
+	solution := s.solution
+	solution.Routes = cloneRoutes(solution.Routes)
+	solution.RouteTimes = s.solver.routeTimes(solution)
+	solution.Distance = s.solver.totalDistance(solution)
+	return solution
+}
+
+// Relocate moves a load to the best feasible position in a route
+func (s *Session) Relocate(load, route int) error {
+	from, pos, err := s.find(load)
+	if err != nil {
+		return err
+	}
+	if err := s.checkRoute(route); err != nil {
+		return err
+	}
+	if from == route {
+		return fmt.Errorf("load %d is already on route %d", s.id(load), route)
+	}
+	moved, ok := s.solver.relocateAt(s.solution, from, pos, route)
+	if !ok {
+		return fmt.Errorf("load %d fits nowhere on route %d", s.id(load), route)
+	}
+	return s.apply(moved)
+}
+
+// Swap exchanges two loads on different routes, each taking the other's position
+func (s *Session) Swap(a, b int) error {
+	i, posA, err := s.find(a)
+	if err != nil {
+		return err
+	}
+	j, posB, err := s.find(b)
+	if err != nil {
+		return err
+	}
+	if i == j {
+		return fmt.Errorf("loads %d and %d are both on route %d", s.id(a), s.id(b), i)
+	}
+	swapped, ok := s.solver.swapLoadsAt(s.solution, i, posA, j, posB)
+	if !ok {
+		return fmt.Errorf("swapping loads %d and %d makes a route infeasible", s.id(a), s.id(b))
+	}
+	return s.apply(swapped)
+}
+
+// ImproveRoute reorders a route with the intra-route optimization selected by
+// Options.Intra, 2-opt by default
+func (s *Session) ImproveRoute(route int) error {
+	if err := s.checkRoute(route); err != nil {
+		return err
+	}
+	return s.apply(s.solver.improveRouteAt(s.solution, route))
+}
+
+// EliminateRoute empties a route by inserting its loads into the others
+func (s *Session) EliminateRoute(route int) error {
+	if err := s.checkRoute(route); err != nil {
+		return err
+	}
+	reduced, ok := s.solver.eliminateRouteAt(s.solution, route)
+	if !ok {
+		return fmt.Errorf("the loads of route %d do not all fit on the other routes", route)
+	}
+	return s.apply(reduced)
+}
+
+// Undo reverts the last applied move, reporting false when there is none
+func (s *Session) Undo() bool {
+	if len(s.history) == 0 {
+		return false
+	}
+	s.solution = s.history[len(s.history)-1]
+	s.history = s.history[:len(s.history)-1]
+	return true
+}
+
+// apply makes a moved solution current, keeping the dropped loads
+func (s *Session) apply(moved Solution) error {
+	moved.Dropped = s.solution.Dropped
+	if !s.solver.isFeasible(moved) {
+		return errors.New("the move makes a route infeasible")
+	}
+	moved.Cost = s.solver.objective.Evaluate(moved)
+	s.history = append(s.history, s.solution)
+	s.solution = moved
+	return nil
+}
+
+// find returns the route and position of a load
+func (s *Session) find(load int) (int, int, error) {
+	for i, route := range s.solution.Routes {
+		for pos, node := range route {
+			if node == load {
+				return i, pos, nil
+			}
+		}
+	}
+	return 0, 0, fmt.Errorf("load %d is on no route", s.id(load))
+}
+
+// id returns the ID of a load index, or the index itself when it is unknown
+func (s *Session) id(load int) int {
+	if load < 1 || load > len(s.solver.loads) {
+		return load
+	}
+	return s.solver.loads[load-1].ID
+}
+
+// checkRoute returns an error when route is not the position of a route
+func (s *Session) checkRoute(route int) error {
+	if route < 0 || route >= len(s.solution.Routes) {
+		return fmt.Errorf("no route %d; routes are numbered 0 to %d", route, len(s.solution.Routes)-1)
+	}
+	return nil
+}
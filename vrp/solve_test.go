@@ -0,0 +1,58 @@
+package vrp
+
+import (
+	"flag"
+	"fmt"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files in testdata with the current output")
+
+// TestSolveGolden pins the routes and cost found for a small instance with a
+// fixed seed, so changes to the search that alter its results are noticed.
+// Run go test -run TestSolveGolden -update after a deliberate change.
+func TestSolveGolden(t *testing.T) {
+	file, err := os.Open(filepath.Join("testdata", "small.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	loads, err := ReadLoads(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	opts := DefaultOptions()
+	opts.Seed = 1
+	opts.Rand = rand.New(rand.NewSource(1))
+	opts.Workers = 1
+	solution, err := Solve(&Problem{Loads: loads}, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b strings.Builder
+	for _, route := range solution.Routes {
+		fmt.Fprintln(&b, strings.Join(strings.Fields(fmt.Sprint(route)), ","))
+	}
+	fmt.Fprintf(&b, "cost=%.6f\n", solution.Cost)
+	got := b.String()
+
+	golden := filepath.Join("testdata", "small.golden")
+	if *update {
+		if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	want, err := os.ReadFile(golden)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("solution differs from %s:\ngot:\n%swant:\n%s", golden, got, want)
+	}
+}
@@ -0,0 +1,65 @@
+package vrp
+
+import (
+	"errors"
+	"math"
+)
+
+// InstanceStats summarizes a problem before solving it
+type InstanceStats struct {
+	Loads int
+
+	// Min and Max bound every pickup and dropoff coordinate
+	Min, Max [2]float64
+
+	// TotalDelivery sums the pickup-to-dropoff distance of every load
+	TotalDelivery float64
+
+	// MinRoundTrip, MaxRoundTrip and MeanRoundTrip describe the time to serve
+	// each load alone on a route from and back to the depot
+	MinRoundTrip  float64
+	MaxRoundTrip  float64
+	MeanRoundTrip float64
+
+	// MinDrivers is the fewest drivers whose shifts could hold every delivery
+	MinDrivers int
+}
+
+// Stats validates that every load fits within the shift limit and returns
+// summary statistics of the problem
+func Stats(p *Problem, opts Options) (InstanceStats, error) {
+	if p == nil || len(p.Loads) == 0 {
+		return InstanceStats{}, errors.New("no loads")
+	}
+
+	s, err := newSolver(p, opts)
+	if err != nil {
+		return InstanceStats{}, err
+	}
+	if err := s.checkLoadsFit(); err != nil {
+		return InstanceStats{}, err
+	}
+
+	stats := InstanceStats{
+		Loads:         len(s.loads),
+		Min:           [2]float64{math.Inf(1), math.Inf(1)},
+		Max:           [2]float64{math.Inf(-1), math.Inf(-1)},
+		TotalDelivery: s.totalDelivery(),
+		MinRoundTrip:  math.Inf(1),
+		MaxRoundTrip:  math.Inf(-1),
+		MinDrivers:    s.minDrivers(),
+	}
+	for i, load := range s.loads {
+		for _, point := range [][2]float64{load.Pickup, load.Dropoff} {
+			for axis := range point {
+				stats.Min[axis] = math.Min(stats.Min[axis], point[axis])
+				stats.Max[axis] = math.Max(stats.Max[axis], point[axis])
+			}
+		}
+		roundTrip := s.routeTime([]int{i + 1})
+		stats.MinRoundTrip = math.Min(stats.MinRoundTrip, roundTrip)
+		stats.MaxRoundTrip = math.Max(stats.MaxRoundTrip, roundTrip)
+		stats.MeanRoundTrip += roundTrip / float64(len(s.loads))
+	}
+	return stats, nil
+}
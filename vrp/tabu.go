@@ -0,0 +1,244 @@
+package vrp
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// tabuSearch implements the Tabu Search algorithm
+func (s *solver) tabuSearch() Solution {
+	start := time.Now()
+
+	// Initialize a random initial solution
+	currentSolution := s.generateInitialSolution()
+	bestSolution := currentSolution
+	s.resetPenalties()
+
+	// tabuList maps solution keys to their remaining tabu tenure in iterations
+	tabuList := make(map[string]int)
+	// visited maps the keys of recent current solutions to the iteration
+	// they were reached in, so revisiting one reveals a cycle
+	visited := make(map[string]int)
+	improvements, aspirations, diversifications, penalized := 0, 0, 0, 0
+
+	// Main loop of the Tabu Search algorithm
+	iteration, lastImprovement := 0, 0
+	var stop string
+	for ; ; iteration++ {
+		if stop = s.stopReason(iteration, lastImprovement, start); stop != "" {
+			break
+		}
+		// Age the tabu list so entries expire after the tabu tenure
+		decayTabuList(tabuList)
+
+		neighbors := s.generateNeighborhood(currentSolution)
+		var bestNeighbor Solution
+		bestScore := math.Inf(1)
+		bestKey := ""
+		bestTabu := false
+
+		// Find the best admissible neighbor by augmented cost, breaking ties
+		// by key so the choice never depends on neighbor order. A tabu
+		// neighbor is admissible only when its true cost beats the best
+		// solution found so far (aspiration).
+		for _, neighbor := range neighbors {
+			key := neighborKey(neighbor)
+			tabu := tabuList[key] > 0
+			if tabu && neighbor.Cost >= bestSolution.Cost {
+				continue
+			}
+			score := s.augmentedCost(neighbor)
+			if score < bestScore || (score == bestScore && key < bestKey) {
+				bestNeighbor, bestScore, bestKey, bestTabu = neighbor, score, key, tabu
+			}
+		}
+
+		// Stay on the current solution, and sample a fresh neighborhood, if
+		// every neighbor was rejected or is worse than it. With guided local
+		// search the current solution is then a local optimum, so penalize it.
+		if math.IsInf(bestScore, 1) || bestScore > s.augmentedCost(currentSolution) {
+			if s.guided {
+				s.penalize(currentSolution)
+				penalized++
+			}
+			s.traceIteration(iteration, currentSolution.Cost, bestSolution.Cost)
+			continue
+		}
+		if bestTabu {
+			aspirations++
+		}
+
+		// Update best solution if necessary
+		if bestNeighbor.Cost < bestSolution.Cost {
+			bestSolution = bestNeighbor
+			improvements++
+			lastImprovement = iteration
+			s.improved(bestSolution, iteration, start)
+		}
+
+		// Update tabu list
+		updateTabuList(tabuList, bestNeighbor, s.tabuListSize)
+
+		currentSolution = bestNeighbor
+
+		// Returning to a recent solution means the search is cycling, so jump
+		// somewhere else with a burst of random moves
+		if last, ok := visited[bestKey]; ok {
+			currentSolution = s.perturb(currentSolution)
+			updateTabuList(tabuList, currentSolution, s.tabuListSize)
+			diversifications++
+			s.logf("iteration %d: revisited the solution of iteration %d, diversified to cost %.2f", iteration, last, currentSolution.Cost)
+			if currentSolution.Cost < bestSolution.Cost {
+				bestSolution = currentSolution
+				improvements++
+				lastImprovement = iteration
+				s.improved(bestSolution, iteration, start)
+			}
+		}
+		recordVisit(visited, neighborKey(currentSolution), iteration)
+
+		// Drivers dominate the cost, so periodically try to remove a route
+		// outright, which swapping and moving single loads rarely achieves
+		if iteration%routeEliminationInterval == 0 {
+			if reduced, ok := s.eliminateRoute(currentSolution); ok && reduced.Cost < currentSolution.Cost {
+				currentSolution = reduced
+				s.logf("iteration %d: eliminated a route, %d routes left", iteration, len(currentSolution.Routes))
+				if currentSolution.Cost < bestSolution.Cost {
+					bestSolution = currentSolution
+					improvements++
+					lastImprovement = iteration
+					s.improved(bestSolution, iteration, start)
+				}
+			}
+		}
+		s.traceIteration(iteration, currentSolution.Cost, bestSolution.Cost)
+	}
+
+	s.logf("stopped by %s after %d iterations: %d improving moves, %d by aspiration, %d diversifications, best cost %.2f", stop, iteration, improvements, aspirations, diversifications, bestSolution.Cost)
+	if s.guided {
+		s.logf("guided local search penalized %d local optima", penalized)
+	}
+	return bestSolution
+}
+
+// stopReason reports why the search should stop, or "" to keep going: the
+// iteration cap, too many iterations since lastImprovement, or the time limit
+func (s *solver) stopReason(iteration, lastImprovement int, start time.Time) string {
+	if s.maxIterations > 0 && iteration >= s.maxIterations {
+		return "iteration cap"
+	}
+	if s.noImprove > 0 && iteration-lastImprovement >= s.noImprove {
+		return "no improvement"
+	}
+	if s.timeLimit > 0 && time.Since(start) >= s.timeLimit {
+		return "time limit"
+	}
+	return ""
+}
+
+// generateNeighborhood creates a set of feasible neighbor solutions
+func (s *solver) generateNeighborhood(solution Solution) []Solution {
+	// Moves draw from the shared RNG, so candidates are generated sequentially
+	candidates := make([]Solution, s.neighborhoodSize)
+	for i := range candidates {
+		candidates[i] = s.randomMove(solution)
+	}
+	s.evaluateNeighbors(candidates)
+
+	var neighbors []Solution
+	for _, candidate := range candidates {
+		// Reject neighbors with infeasible routes
+		if math.IsInf(candidate.Cost, 1) {
+			continue
+		}
+		neighbors = append(neighbors, candidate)
+	}
+
+	return neighbors
+}
+
+// updateTabuList makes the solution tabu for the next tenure iterations
+func updateTabuList(tabuList map[string]int, solution Solution, tenure int) {
+	tabuList[neighborKey(solution)] = tenure
+}
+
+// decayTabuList decrements every tenure by one iteration, removing expired
+// entries. Every entry is visited, so map iteration order does not matter.
+func decayTabuList(tabuList map[string]int) {
+	for key := range tabuList {
+		tabuList[key]--
+		if tabuList[key] <= 0 {
+			delete(tabuList, key)
+		}
+	}
+}
+
+// recordVisit notes that the search reached a solution in an iteration and
+// forgets visits older than cycleWindow
+func recordVisit(visited map[string]int, key string, iteration int) {
+	for k, last := range visited {
+		if iteration-last >= cycleWindow {
+			delete(visited, k)
+		}
+	}
+	visited[key] = iteration
+}
+
+// neighborKey generates a unique key for a solution, including its dropped
+// loads. Routes are keyed in the order of their first load, so solutions that
+// only list the same routes in a different order share a key.
+func neighborKey(solution Solution) string {
+	routes := append([][]int(nil), solution.Routes...)
+	sort.Slice(routes, func(i, j int) bool { return firstLoad(routes[i]) < firstLoad(routes[j]) })
+	var sb strings.Builder
+	for _, route := range routes {
+		sb.WriteString(fmt.Sprintf("%v-", route))
+	}
+	if len(solution.Dropped) > 0 {
+		sb.WriteString(fmt.Sprintf("dropped%v", solution.Dropped))
+	}
+	return sb.String()
+}
+
+// firstLoad returns the first load of a route, or 0 when it is empty
+func firstLoad(route []int) int {
+	if len(route) == 0 {
+		return 0
+	}
+	return route[0]
+}
+
+// calculateCost computes the total cost of a solution: weighted route times
+// and any costed waiting, the weighted driver cost and the penalty for dropped
+// loads. It is the default objective.
+func (s *solver) calculateCost(solution Solution) float64 {
+	s.counters.costCalls.Add(1)
+	totalDistance := 0.0
+	for _, route := range solution.Routes {
+		totalDistance += s.routeCost(route)
+	}
+	return totalDistance + float64(len(solution.Routes))*s.costPerDriver + s.dropCost(solution)
+}
+
+// dropCost is the total penalty for the loads a solution leaves undelivered
+func (s *solver) dropCost(solution Solution) float64 {
+	total := 0.0
+	for _, node := range solution.Dropped {
+		total += s.dropPenalty * (1 + s.loads[node-1].Priority)
+	}
+	return total
+}
+
+// routeCost is the cost of a single route excluding the driver cost: its
+// time, plus any waiting when waiting is costed, scaled by the distance weight
+func (s *solver) routeCost(route []int) float64 {
+	cost := s.routeTime(route)
+	if s.waitingCost {
+		_, waiting, _ := s.routeSchedule(route)
+		cost += waiting
+	}
+	return cost * s.distanceWeight
+}
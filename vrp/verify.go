@@ -0,0 +1,91 @@
+package vrp
+
+import (
+	"errors"
+	"fmt"
+)
+
+// Evaluate checks externally produced routes against the problem and returns
+// them as a Solution with its recomputed cost. It fails when a load is
+// repeated, or missing unless AllowDrops counts it as dropped, when there are
+// more routes than MaxDrivers, or when a route breaks the shift limit,
+// capacity, load limit, a time window, a pinned group or a conflict.
+func Evaluate(p *Problem, routes [][]int, opts Options) (Solution, error) {
+	if p == nil {
+		return Solution{}, errors.New("nil problem")
+	}
+
+	s, err := newSolver(p, opts)
+	if err != nil {
+		return Solution{}, err
+	}
+	if err := s.resolveGroups(opts.Groups); err != nil {
+		return Solution{}, err
+	}
+	if err := s.resolveConflicts(opts.Conflicts); err != nil {
+		return Solution{}, err
+	}
+	solution := Solution{Routes: routes}
+	if s.allowDrops {
+		solution.Dropped = s.undelivered(routes)
+	}
+	if err := s.validateSolution(solution); err != nil {
+		return Solution{}, err
+	}
+	if s.maxDrivers > 0 && len(routes) > s.maxDrivers {
+		return Solution{}, fmt.Errorf("uses %d drivers, exceeding the limit of %d", len(routes), s.maxDrivers)
+	}
+	for i, route := range routes {
+		if violation := s.routeViolation(route); violation != "" {
+			return Solution{}, fmt.Errorf("route %d is infeasible: %s", i, violation)
+		}
+	}
+	solution.Cost = s.objective.Evaluate(solution)
+	solution.RouteTimes = s.routeTimes(solution)
+	solution.Distance = s.totalDistance(solution)
+	solution.DropPenalty = s.dropCost(solution)
+	return solution, nil
+}
+
+// undelivered returns the sorted loads that appear on none of the routes
+func (s *solver) undelivered(routes [][]int) []int {
+	delivered := make(map[int]bool)
+	for _, route := range routes {
+		for _, node := range route {
+			delivered[node] = true
+		}
+	}
+	var missing []int
+	for node := 1; node <= len(s.loads); node++ {
+		if !delivered[node] {
+			missing = append(missing, node)
+		}
+	}
+	return missing
+}
+
+// routeViolation describes why a route is infeasible, or returns "" when it is feasible
+func (s *solver) routeViolation(route []int) string {
+	if s.maxLoads > 0 && len(route) > s.maxLoads {
+		return fmt.Sprintf("has %d loads, exceeding the limit of %d", len(route), s.maxLoads)
+	}
+	if id := s.splitGroup(route); id != 0 {
+		return fmt.Sprintf("load %d is separated from the rest of its group", id)
+	}
+	if a, b := s.routeConflict(route); a != 0 {
+		return fmt.Sprintf("loads %d and %d may not share a route", a, b)
+	}
+	if s.capacity > 0 {
+		if demand := s.routeDemand(route); demand > s.capacity {
+			return fmt.Sprintf("demand %.2f exceeds capacity %.2f", demand, s.capacity)
+		}
+	}
+	duration, _, onTime := s.routeSchedule(route)
+	if !onTime {
+		return "a load is reached after its due time"
+	}
+	if duration > s.shiftTime {
+		return fmt.Sprintf("takes %.2f minutes, exceeding the %.0f-minute shift limit", duration, s.shiftTime)
+	}
+	return ""
+}
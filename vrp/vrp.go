@@ -0,0 +1,581 @@
+// Package vrp solves the Vehicle Routing Problem with a Tabu Search heuristic.
+package vrp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"math/rand"
+	"runtime"
+	"time"
+)
+
+// MaxShiftTime is the default longest a driver may work, in minutes (12 hours)
+const MaxShiftTime = 720.0
+
+// Constants for the algorithm parameters
+const (
+	costPerDriver    = 500.0
+	dropPenalty      = 1000.0
+	startTemperature = 100.0
+	coolingRate      = 0.95
+	tabuListSize     = 10
+	maxIterations    = 100
+	neighborhoodSize = 10
+
+	// minNeighborhoodSize and maxNeighborhoodSize clamp a neighborhood sized
+	// by NeighborhoodFraction
+	minNeighborhoodSize = 5
+	maxNeighborhoodSize = 200
+
+	// routeEliminationInterval is how often, in iterations, the tabu search
+	// tries to empty its smallest route
+	routeEliminationInterval = 10
+
+	// cycleWindow is how many iterations back the tabu search looks for a
+	// repeat of the current solution before treating it as cycling, and
+	// perturbationMoves how many random moves a diversification applies
+	cycleWindow       = 50
+	perturbationMoves = 10
+
+	// eliteSize is how many of the best restart results are kept to seed
+	// later restarts from
+	eliteSize = 5
+
+	// dropMoveOdds makes one in this many moves drop or reinsert a load when
+	// drops are allowed
+	dropMoveOdds = 10
+
+	// maxSegmentLength bounds the chains 3-opt moves within a route
+	maxSegmentLength = 3
+
+	// penaltyWeightFactor scales the weight of a guided local search penalty
+	// relative to the average edge of the first local optimum
+	penaltyWeightFactor = 0.1
+)
+
+// Load represents a delivery task with pickup and dropoff locations
+type Load struct {
+	ID      int
+	Pickup  [2]float64
+	Dropoff [2]float64
+	Demand  float64 // Weight counted against vehicle capacity
+
+	// ReadyTime and DueTime bound when the pickup may be reached, in minutes
+	// since the start of the shift. Arriving early means waiting until
+	// ReadyTime; a zero DueTime means there is no deadline.
+	ReadyTime float64
+	DueTime   float64
+
+	// Priority raises the cost of dropping the load when drops are allowed:
+	// each drop costs DropPenalty times 1+Priority, so it is at least -1
+	Priority float64
+}
+
+// Problem holds the loads that have to be delivered
+type Problem struct {
+	Loads []Load
+}
+
+// Solution represents a set of routes and their associated cost.
+// Routes hold 1-based indices into Problem.Loads.
+type Solution struct {
+	Routes [][]int
+	Cost   float64
+
+	// RouteTimes holds the duration of each route in minutes, including any
+	// waiting. It is filled in for the solutions returned by Solve and Evaluate.
+	RouteTimes []float64
+
+	// Distance is the total distance the routes travel, unweighted and
+	// without waiting, filled in alongside RouteTimes
+	Distance float64
+
+	// Dropped holds the sorted 1-based indices of loads left undelivered,
+	// which only happens with AllowDrops, and DropPenalty their total
+	// penalty, which is included in Cost
+	Dropped     []int
+	DropPenalty float64
+}
+
+// Options configures a call to Solve
+type Options struct {
+	Seed     int64        // Seed for the random number generator
+	Rand     *rand.Rand   // Random source overriding Seed, e.g. to pin behavior in tests
+	Distance DistanceFunc // Distance metric, Euclidean when nil
+
+	// Normalize evaluates Distance on coordinates translated so the first
+	// depot is at the origin and scaled into [-1, 1], scaling the result back
+	// to the original units. It keeps huge coordinates numerically stable but
+	// is only valid for metrics that are translation invariant and scale
+	// linearly, so not for HaversineDistance.
+	Normalize bool
+
+	// Matrix, when non-nil, replaces the distances computed from coordinates
+	// with a precomputed, possibly asymmetric, (loads+1)x(loads+1) matrix.
+	// Index 0 is the depot and index i is load i. Off the diagonal, row i,
+	// column j is the distance from the dropoff of i, or the depot, to the
+	// pickup of j, or the depot; the diagonal holds each load's
+	// pickup-to-dropoff distance. Depot and Depots are ignored.
+	Matrix [][]float64
+
+	Capacity float64    // Maximum total demand per route, unlimited when zero
+	MaxLoads int        // Maximum number of loads per route, unlimited when zero
+	Depot    [2]float64 // Where every route starts and ends
+
+	// MaxDrivers caps the number of routes, unlimited when zero. Construction
+	// packs loads into at most this many routes and the search never adds
+	// one; Solve fails when no solution within the cap is found.
+	MaxDrivers int
+
+	// AllowDrops lets the solver leave loads undelivered, at a cost of
+	// DropPenalty times 1+Priority each, rather than fail when they cannot
+	// all be served, e.g. within MaxDrivers. DropPenalty is used as given, so
+	// zero makes drops free; DefaultOptions sets the standard 1000.
+	AllowDrops  bool
+	DropPenalty float64
+
+	// ShiftTime is the longest a driver may work, in minutes. Zero uses
+	// MaxShiftTime.
+	ShiftTime float64
+
+	// AllowReload lets a vehicle return to the depot mid-route to unload
+	// whenever the next load would exceed Capacity, so Capacity bounds each
+	// trip rather than the whole route. The detour counts against the shift.
+	AllowReload bool
+
+	// Depots, when non-empty, replaces Depot with several depots. Each route
+	// starts at the depot nearest its first pickup and ends at the depot
+	// nearest its last dropoff.
+	Depots [][2]float64
+
+	// Groups lists sets of load IDs that must all be delivered on the same
+	// route. Construction inserts each group as a unit, in the order given,
+	// and no move may split one.
+	Groups [][]int
+
+	// Conflicts lists pairs of load IDs that must never share a route
+	Conflicts [][2]int
+
+	// CostPerDriver is the fixed cost added for every route. It is used as
+	// given, so zero makes the objective pure distance; DefaultOptions sets
+	// the standard 500.
+	CostPerDriver float64
+
+	// DistanceWeight scales route time and DriverWeight scales the driver
+	// cost in the objective, trading mileage against fleet size. They are
+	// used as given, so zero drops a term; DefaultOptions sets both to 1 and
+	// negative weights are rejected.
+	DistanceWeight float64
+	DriverWeight   float64
+
+	// WaitingCost adds time spent waiting for ready times to the solution cost
+	WaitingCost bool
+
+	// OpenRoutes lets drivers end their shift at their last dropoff instead
+	// of driving back to the depot, so the return leg counts neither toward
+	// the cost nor against the shift limit
+	OpenRoutes bool
+
+	// Objective selects what the search minimizes and Solution.Cost reports:
+	// "cost" (the default) is route time plus the driver cost, "balance" adds
+	// the spread between the longest and shortest route, and "slack"
+	// subtracts the slack left on the longest route. CustomObjective, when
+	// non-nil, replaces it; the solutions it sees have RouteTimes filled in.
+	// It may be called from several goroutines at once, so it must be safe
+	// for concurrent use.
+	Objective       string
+	CustomObjective Objective
+
+	// BalanceWeight adds the spread between the longest and shortest route,
+	// in minutes and scaled by this weight, to the "cost" and "slack"
+	// objectives. Zero leaves them unchanged; the "balance" objective uses a
+	// weight of 1 unless another is given.
+	BalanceWeight float64
+
+	// MaxIterations caps the number of search iterations and TimeLimit caps the
+	// wall-clock time; whichever is reached first stops the search. A zero
+	// MaxIterations means no cap when a TimeLimit is set, and the default of
+	// 100 iterations otherwise.
+	MaxIterations int
+	TimeLimit     time.Duration
+
+	// NoImprove stops the search early once the best solution has not improved
+	// for this many consecutive iterations. Zero disables early stopping.
+	NoImprove int
+
+	// Restarts runs the whole search this many times from fresh initial
+	// solutions and keeps the best result. Each restart uses a seed derived
+	// from Seed and gets an equal share of TimeLimit. Zero means one run.
+	Restarts int
+
+	// EliteRestartProb is the probability that a restart after the first
+	// starts from a perturbed copy of one of the best five solutions found by
+	// earlier restarts, better ones being more likely, instead of building a
+	// fresh initial solution
+	EliteRestartProb float64
+
+	// TabuListSize is the number of iterations a visited solution stays tabu
+	// and NeighborhoodSize the number of neighbors evaluated per iteration.
+	// Zero values use the defaults of 10.
+	TabuListSize     int
+	NeighborhoodSize int
+
+	// NeighborhoodFraction, when NeighborhoodSize is zero, sizes the
+	// neighborhood as this fraction of the number of loads, clamped to between
+	// 5 and 200 neighbors, so larger instances are searched more widely.
+	NeighborhoodFraction float64
+
+	// Algorithm selects the search strategy: "tabu" (the default) or "annealing"
+	Algorithm string
+
+	// GuidedLocalSearch makes the tabu search penalize the longest edges of
+	// each local optimum it gets stuck in and compare neighbors by their cost
+	// plus the penalties of their edges, steering it out of the optimum. The
+	// best solution is still chosen by its true cost.
+	GuidedLocalSearch bool
+
+	// Init selects how the initial solution is built: "random" (the default)
+	// picks loads with probability inversely proportional to distance,
+	// "greedy" always picks the nearest feasible load, "insertion" inserts
+	// each load where it adds the least cost across all routes,
+	// "sequential" packs loads into routes in input order, and "savings"
+	// joins routes by the Clarke-Wright savings heuristic
+	Init string
+	// Intra selects how loads are reordered within a route, both by the
+	// search and by Polish: "2opt" (the default) reverses segments, and
+	// "3opt" also moves chains of up to three loads, optionally reversed.
+	// "lk" is accepted as an alias for this bounded 3-opt.
+	Intra string
+	// StartTemperature and CoolingRate configure simulated annealing. The
+	// temperature is multiplied by CoolingRate after every iteration. Zero
+	// values use the defaults of 100 and 0.95.
+	StartTemperature float64
+	CoolingRate      float64
+
+	// Polish merges routes of the final solution while that saves cost, then
+	// runs the Intra optimization to convergence on every route. It never
+	// increases the cost; DefaultOptions enables it.
+	Polish bool
+
+	// Workers caps how many goroutines evaluate neighbors in parallel. Fewer
+	// workers trade speed for less CPU contention; 1 evaluates sequentially.
+	// Zero uses runtime.NumCPU().
+	Workers int
+
+	// Log receives progress messages during the search, silent when nil
+	Log io.Writer
+
+	// Trace, when non-nil, receives a CSV convergence curve with an
+	// "iteration,current_cost,best_cost" row after every search iteration.
+	// Restarts append their curves one after another. Writes are not
+	// buffered, so wrap files in a bufio.Writer.
+	Trace io.Writer
+
+	// OnImprovement, when non-nil, is called from the searching goroutine
+	// each time a better solution is found, with the iteration it was found
+	// in. Across restarts it only sees solutions better than any reported
+	// before. The solution is a copy with RouteTimes filled in, but it is not
+	// yet polished.
+	OnImprovement func(solution Solution, iteration int)
+}
+
+// DefaultOptions returns the options used by the command-line solver
+func DefaultOptions() Options {
+	return Options{
+		CostPerDriver:    costPerDriver,
+		DropPenalty:      dropPenalty,
+		DistanceWeight:   1,
+		DriverWeight:     1,
+		TabuListSize:     tabuListSize,
+		NeighborhoodSize: neighborhoodSize,
+		StartTemperature: startTemperature,
+		CoolingRate:      coolingRate,
+		Polish:           true,
+	}
+}
+
+// solver holds the problem data and precomputed distances for a single Solve call
+type solver struct {
+	loads            []Load
+	distanceMatrix   [][]float64
+	reloadMatrix     [][]float64 // Reload detours through a single depot, with several depots
+	deliveryDistance []float64
+	distance         DistanceFunc
+	normalize        bool
+	depots           [][2]float64
+	capacity         float64
+	maxLoads         int
+	maxDrivers       int
+	allowDrops       bool
+	dropPenalty      float64
+	shiftTime        float64
+	allowReload      bool
+	openRoutes       bool
+	balanceWeight    float64
+	groups           [][]int // Pinned groups as route indices
+	groupOf          []int   // 1-based group of each route index, 0 when not pinned
+	conflicts        [][]int // Route indices each route index may not share a route with
+	costPerDriver    float64 // Driver cost scaled by the driver weight
+	distanceWeight   float64
+	waitingCost      bool
+	init             string
+	intra            string
+	rng              *rand.Rand
+	cache            *costCache
+	counters         *counters
+	objective        Objective
+	maxIterations    int
+	timeLimit        time.Duration
+	noImprove        int
+	tabuListSize     int
+	neighborhoodSize int
+	startTemperature float64
+	coolingRate      float64
+	workers          int
+	log              io.Writer
+	trace            io.Writer
+	onImprovement    func(Solution, int)
+	reportedCost     float64 // Cost of the last solution passed to onImprovement
+	eliteRestartProb float64
+	elite            []Solution // Best restart results, cheapest first
+	restartFrom      *Solution  // Solution the next search starts from instead of constructing one
+	guided           bool
+	penalties        map[edge]int // Guided local search penalty of each edge
+	penaltyWeight    float64      // Cost of one penalty, set at the first local optimum
+}
+
+// Solve runs the selected search algorithm on the problem and returns the best solution found
+func Solve(p *Problem, opts Options) (Solution, error) {
+	if p == nil {
+		return Solution{}, errors.New("nil problem")
+	}
+
+	var search func(*solver) Solution
+	switch opts.Algorithm {
+	case "", "tabu":
+		search = (*solver).tabuSearch
+	case "annealing":
+		search = (*solver).simulatedAnnealing
+	default:
+		return Solution{}, fmt.Errorf("unknown algorithm %q", opts.Algorithm)
+	}
+	if err := validateInit(opts.Init); err != nil {
+		return Solution{}, err
+	}
+	switch opts.Intra {
+	case "", "2opt", "3opt", "lk":
+	default:
+		return Solution{}, fmt.Errorf("unknown intra-route optimization %q", opts.Intra)
+	}
+
+	s, err := newSolver(p, opts)
+	if err != nil {
+		return Solution{}, err
+	}
+	if err := s.resolveGroups(opts.Groups); err != nil {
+		return Solution{}, err
+	}
+	if err := s.resolveConflicts(opts.Conflicts); err != nil {
+		return Solution{}, err
+	}
+	if err := s.checkLoadsFit(); err != nil {
+		return Solution{}, err
+	}
+	if err := s.checkGroupsFit(); err != nil {
+		return Solution{}, err
+	}
+	// With drops allowed, loads beyond what the fleet can carry are dropped
+	// instead
+	if s.maxDrivers > 0 && !s.allowDrops && s.minDrivers() > s.maxDrivers {
+		return Solution{}, &InfeasibleError{Reason: fmt.Sprintf("the deliveries alone need at least %d drivers, more than the %d allowed", s.minDrivers(), s.maxDrivers)}
+	}
+	restarts := opts.Restarts
+	if restarts < 1 {
+		restarts = 1
+	}
+	s.timeLimit /= time.Duration(restarts)
+
+	if s.trace != nil {
+		fmt.Fprintln(s.trace, "iteration,current_cost,best_cost")
+	}
+
+	// Run the selected search algorithm
+	solution := s.runRestarts(search, opts.Seed, opts.Rand, restarts)
+	if opts.Polish {
+		solution = s.polish(solution)
+	}
+	if err := s.validateSolution(solution); err != nil {
+		return Solution{}, fmt.Errorf("invalid solution: %w", err)
+	}
+	if s.maxDrivers > 0 && !s.allowDrops && len(solution.Routes) > s.maxDrivers {
+		return Solution{}, &InfeasibleError{Reason: fmt.Sprintf("no solution with at most %d drivers found; the best needs %d", s.maxDrivers, len(solution.Routes))}
+	}
+	solution.RouteTimes = s.routeTimes(solution)
+	solution.Distance = s.totalDistance(solution)
+	solution.DropPenalty = s.dropCost(solution)
+	s.checkRoutes(solution)
+	s.logf("%v", s.counters)
+	if len(solution.Dropped) > 0 {
+		s.logf("dropped %d loads at a penalty of %.2f", len(solution.Dropped), solution.DropPenalty)
+	} else if bound := s.lowerBound(); bound > 0 {
+		s.logf("lower bound %.2f, gap %.1f%%", bound, 100*(solution.Cost-bound)/bound)
+	}
+	return solution, nil
+}
+
+// newSolver applies option defaults and precomputes the distance matrices for a problem
+func newSolver(p *Problem, opts Options) (*solver, error) {
+	s := &solver{
+		loads:         p.Loads,
+		distance:      opts.Distance,
+		normalize:     opts.Normalize,
+		depots:        opts.Depots,
+		capacity:      opts.Capacity,
+		maxLoads:      opts.MaxLoads,
+		maxDrivers:    opts.MaxDrivers,
+		allowDrops:    opts.AllowDrops,
+		dropPenalty:   opts.DropPenalty,
+		shiftTime:     opts.ShiftTime,
+		allowReload:   opts.AllowReload,
+		openRoutes:    opts.OpenRoutes,
+		balanceWeight: opts.BalanceWeight,
+		waitingCost:   opts.WaitingCost,
+		init:          opts.Init,
+		intra:         opts.Intra,
+		cache:         newCostCache(),
+		counters:      &counters{},
+
+		maxIterations: opts.MaxIterations,
+		timeLimit:     opts.TimeLimit,
+		noImprove:     opts.NoImprove,
+
+		costPerDriver:    opts.CostPerDriver,
+		tabuListSize:     opts.TabuListSize,
+		neighborhoodSize: opts.NeighborhoodSize,
+
+		startTemperature: opts.StartTemperature,
+		coolingRate:      opts.CoolingRate,
+		workers:          opts.Workers,
+		log:              opts.Log,
+		trace:            opts.Trace,
+
+		onImprovement:    opts.OnImprovement,
+		eliteRestartProb: opts.EliteRestartProb,
+		guided:           opts.GuidedLocalSearch,
+		reportedCost:     math.Inf(1),
+	}
+	if s.distance == nil {
+		s.distance = EuclideanDistance
+	}
+	if len(s.depots) == 0 {
+		s.depots = [][2]float64{opts.Depot}
+	}
+	if s.shiftTime < 0 {
+		return nil, fmt.Errorf("shift time %g is negative", s.shiftTime)
+	}
+	if s.shiftTime == 0 {
+		s.shiftTime = MaxShiftTime
+	}
+	if opts.DistanceWeight < 0 {
+		return nil, fmt.Errorf("distance weight %g is negative", opts.DistanceWeight)
+	}
+	if s.balanceWeight < 0 {
+		return nil, fmt.Errorf("balance weight %g is negative", s.balanceWeight)
+	}
+	if opts.DriverWeight < 0 {
+		return nil, fmt.Errorf("driver weight %g is negative", opts.DriverWeight)
+	}
+	if s.dropPenalty < 0 {
+		return nil, fmt.Errorf("drop penalty %g is negative", s.dropPenalty)
+	}
+	s.distanceWeight = opts.DistanceWeight
+	s.costPerDriver *= opts.DriverWeight
+	if s.maxIterations < 0 {
+		return nil, fmt.Errorf("max iterations %d is negative", s.maxIterations)
+	}
+	if s.timeLimit < 0 {
+		return nil, fmt.Errorf("time limit %v is negative", s.timeLimit)
+	}
+	if s.maxIterations == 0 && s.timeLimit == 0 {
+		s.maxIterations = maxIterations
+	}
+	if s.tabuListSize == 0 {
+		s.tabuListSize = tabuListSize
+	}
+	if s.eliteRestartProb < 0 || s.eliteRestartProb > 1 {
+		return nil, fmt.Errorf("elite restart probability %g is outside [0, 1]", s.eliteRestartProb)
+	}
+	if opts.NeighborhoodFraction < 0 || opts.NeighborhoodFraction > 1 {
+		return nil, fmt.Errorf("neighborhood fraction %g is outside [0, 1]", opts.NeighborhoodFraction)
+	}
+	if s.neighborhoodSize == 0 && opts.NeighborhoodFraction > 0 {
+		size := int(math.Round(opts.NeighborhoodFraction * float64(len(s.loads))))
+		s.neighborhoodSize = min(max(size, minNeighborhoodSize), maxNeighborhoodSize)
+		s.logf("neighborhood size %d", s.neighborhoodSize)
+	}
+	if s.neighborhoodSize == 0 {
+		s.neighborhoodSize = neighborhoodSize
+	}
+	if s.startTemperature == 0 {
+		s.startTemperature = startTemperature
+	}
+	if s.coolingRate == 0 {
+		s.coolingRate = coolingRate
+	}
+	if s.workers == 0 {
+		s.workers = runtime.NumCPU()
+	}
+	objective, err := s.newObjective(opts.Objective)
+	if err != nil {
+		return nil, err
+	}
+	s.objective = objective
+	if opts.CustomObjective != nil {
+		s.objective = s.customObjective(opts.CustomObjective)
+	}
+	// A precomputed matrix ignores coordinates, so only check them when the
+	// haversine distance will be computed from them
+	if opts.Matrix == nil && isHaversine(s.distance) {
+		if err := s.checkGeographic(); err != nil {
+			return nil, err
+		}
+	}
+	// Initialize distance matrices
+	if err := s.initializeMatrices(opts.Matrix); err != nil {
+		return nil, err
+	}
+	if err := s.checkMatrix(); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// improved logs a new best solution found by a search started at start and
+// reports it to the OnImprovement hook when it beats every solution reported so far
+func (s *solver) improved(solution Solution, iteration int, start time.Time) {
+	s.logf("iteration %d: best cost %.2f after %s", iteration, solution.Cost, time.Since(start).Round(time.Millisecond))
+	if s.onImprovement == nil || solution.Cost >= s.reportedCost {
+		return
+	}
+	s.reportedCost = solution.Cost
+	solution.Routes = cloneRoutes(solution.Routes)
+	solution.RouteTimes = s.routeTimes(solution)
+	solution.Distance = s.totalDistance(solution)
+	s.onImprovement(solution, iteration)
+}
+
+// traceIteration writes the costs at the end of an iteration to the trace, if any
+func (s *solver) traceIteration(iteration int, current, best float64) {
+	if s.trace != nil {
+		fmt.Fprintf(s.trace, "%d,%.2f,%.2f\n", iteration, current, best)
+	}
+}
+
+// logf writes a progress message to the configured log, if any
+func (s *solver) logf(format string, args ...interface{}) {
+	if s.log != nil {
+		fmt.Fprintf(s.log, format+"\n", args...)
+	}
+}